@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// FileAuditLog stand-in for the audit database, one JSON entry per line in a local file
+type FileAuditLog struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+// NewFileAuditLog audit log appending to the file at path
+func NewFileAuditLog(path string) *FileAuditLog {
+	return &FileAuditLog{Path: path}
+}
+
+// RecordAudit appends entry to the file
+func (l *FileAuditLog) RecordAudit(entry *servicelib.AuditEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// GetAuditEntries entries recorded from and including from, until but not including to
+func (l *FileAuditLog) GetAuditEntries(from time.Time, to time.Time) ([]*servicelib.AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return []*servicelib.AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []*servicelib.AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := &servicelib.AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, errors.Wrapf(err, "Invalid audit entry on line %d", line)
+		}
+
+		if !entry.Time.Before(from) && entry.Time.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	auditLog := NewFileAuditLog(filepath.Join(dir, "audit.jsonl"))
+
+	entries, err := auditLog.GetAuditEntries(now.AddDate(0, -1, 0), now)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	august := &servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: now.AddDate(0, -1, 0), CustomerID: 1, BookIDs: []string{"11111"}, Tier: servicelib.TierAdult}
+	september := &servicelib.AuditEntry{Action: servicelib.AuditActionCollectFee, Time: now, CustomerID: 1, BookIDs: []string{"11111"}, Amount: 20, DaysLate: 2}
+	assert.Nil(t, auditLog.RecordAudit(august))
+	assert.Nil(t, auditLog.RecordAudit(september))
+
+	entries, err = auditLog.GetAuditEntries(now.AddDate(0, -1, 0), now)
+	assert.Nil(t, err)
+	assert.Equal(t, []*servicelib.AuditEntry{august}, entries)
+
+	entries, err = auditLog.GetAuditEntries(now, now.AddDate(0, 1, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, []*servicelib.AuditEntry{september}, entries)
+}
+
+func TestFileAuditLogInvalidEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.jsonl")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{}\nnot json\n"), 0644))
+
+	_, err = NewFileAuditLog(path).GetAuditEntries(time.Time{}, time.Now())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid audit entry on line 2")
+}
@@ -0,0 +1,139 @@
+// Command library runs the library's batch jobs and reports
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/eirikbell/slap/audit"
+	"github.com/eirikbell/slap/reports"
+	slap "github.com/eirikbell/slap/slap"
+	"github.com/pkg/errors"
+)
+
+const usage = `Usage: library <command> [flags]
+
+Commands:
+  stats     circulation statistics for a month from the audit log
+  explain   rules evaluated in a lending decision, from a saved decision trace
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "stats":
+		err = runStats(os.Args[2:], os.Stdout)
+	case "explain":
+		err = runExplain(os.Args[2:], os.Stdout)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runStats(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	auditPath := flags.String("audit", "audit.jsonl", "audit log file, one JSON entry per line")
+	month := flags.String("month", time.Now().AddDate(0, -1, 0).Format("2006-01"), "month to report, as YYYY-MM")
+	format := flags.String("format", "text", "output format: text, csv or json")
+	top := flags.Int("top", reports.DefaultTopBooks, "number of most borrowed books to list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := time.Parse("2006-01", *month)
+	if err != nil {
+		return fmt.Errorf("Invalid month %q, use YYYY-MM", *month)
+	}
+
+	stats, err := reports.Circulation(audit.NewFileAuditLog(*auditPath), from, from.AddDate(0, 1, 0), *top)
+	if err != nil {
+		return err
+	}
+
+	return writeStats(w, stats, *format)
+}
+
+func writeStats(w io.Writer, stats *reports.CirculationStats, format string) error {
+	var write func(io.Writer, *reports.Table) error
+	switch format {
+	case "json":
+		return reports.WriteJSON(w, stats)
+	case "csv":
+		write = reports.WriteCSV
+	case "text":
+		write = reports.WriteText
+	default:
+		return fmt.Errorf("Unknown format %q", format)
+	}
+
+	tables := []*reports.Table{
+		stats.SummaryTable(),
+		reports.CountTable("Day", stats.LendsPerDay),
+		reports.CountTable("Branch", stats.LendsByBranch),
+		reports.CountTable("Tier", stats.LendsByTier),
+		reports.CountTable("Book", stats.MostBorrowed),
+	}
+	for i, table := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := write(w, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runExplain(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	tracePath := flags.String("trace", "decision.json", "decision trace as returned by the lending API")
+	format := flags.String("format", "text", "output format: text, csv or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	trace, err := readDecisionTrace(*tracePath)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return reports.WriteJSON(w, trace)
+	case "csv":
+		return reports.WriteCSV(w, reports.DecisionTable(trace))
+	case "text":
+		return reports.WriteText(w, reports.DecisionTable(trace))
+	default:
+		return fmt.Errorf("Unknown format %q", *format)
+	}
+}
+
+func readDecisionTrace(path string) (*slap.DecisionTrace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	trace := &slap.DecisionTrace{}
+	if err := json.NewDecoder(f).Decode(trace); err != nil {
+		return nil, errors.Wrapf(err, "Cannot read decision trace %s", path)
+	}
+	return trace, nil
+}
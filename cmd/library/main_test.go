@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/audit"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "library")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.jsonl")
+	auditLog := audit.NewFileAuditLog(path)
+	assert.Nil(t, auditLog.RecordAudit(&servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: time.Date(2019, time.September, 2, 10, 0, 0, 0, time.UTC), BookIDs: []string{"11111"}, Tier: servicelib.TierAdult}))
+	assert.Nil(t, auditLog.RecordAudit(&servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: time.Date(2019, time.October, 2, 10, 0, 0, 0, time.UTC), BookIDs: []string{"22222"}, Tier: servicelib.TierAdult}))
+
+	var buf bytes.Buffer
+	err = runStats([]string{"-audit", path, "-month", "2019-09", "-format", "csv"}, &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "Statistic,Value\nLends,1\nRenewals,0\nRenewal rate,0%\nLate returns,0\nAverage days late,0.0\n"+
+		"Fee revenue,0\nFees charged,0\nFees waived,0\nBlocked payments,0\nBlocked payment amount,0\n\n"+
+		"Day,Lends\n2019-09-02,1\n\n"+
+		"Branch,Lends\nunknown,1\n\n"+
+		"Tier,Lends\nadult,1\n\n"+
+		"Book,Lends\n11111,1\n", buf.String())
+}
+
+func TestRunStatsInvalidFlags(t *testing.T) {
+	var buf bytes.Buffer
+	err := runStats([]string{"-month", "September"}, &buf)
+	assert.Error(t, err)
+	assert.Equal(t, `Invalid month "September", use YYYY-MM`, err.Error())
+
+	err = runStats([]string{"-audit", filepath.Join(os.TempDir(), "missing.jsonl"), "-month", "2019-09", "-format", "xml"}, &buf)
+	assert.Error(t, err)
+	assert.Equal(t, `Unknown format "xml"`, err.Error())
+}
+
+func TestRunExplain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "library")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "decision.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"evaluations":[
+		{"rule":"book-id-valid","passed":true,"values":{"bookId":"12345","length":5,"minLength":5}},
+		{"rule":"account-not-locked","passed":false,"values":{"locked":true,"lockReason":"overdue books"}}]}`), 0644))
+
+	var buf bytes.Buffer
+	err = runExplain([]string{"-trace", path}, &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "Rule                Result  Values\n"+
+		"book-id-valid       pass    bookId=12345 length=5 minLength=5\n"+
+		"account-not-locked  fail    lockReason=overdue books locked=true\n", buf.String())
+
+	err = runExplain([]string{"-trace", filepath.Join(dir, "missing.json")}, &buf)
+	assert.Error(t, err)
+}
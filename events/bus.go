@@ -0,0 +1,59 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Handler reacts to a published event
+type Handler func(Event) error
+
+// Publisher publishes events to whoever is interested
+type Publisher interface {
+	Publish(Event) error
+}
+
+// EventBus routes published events to the handlers subscribed to their type
+type EventBus interface {
+	Publisher
+	Subscribe(Type, Handler)
+}
+
+// InProcessBus event bus calling handlers synchronously in the publishing goroutine
+type InProcessBus struct {
+	handlers map[Type][]Handler
+	mutex    sync.RWMutex
+}
+
+// NewInProcessBus bus without subscribers
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{handlers: map[Type][]Handler{}}
+}
+
+// Subscribe handler to events of type
+func (b *InProcessBus) Subscribe(eventType Type, handler Handler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish event to every handler subscribed to its type
+func (b *InProcessBus) Publish(event Event) error {
+	b.mutex.RLock()
+	handlers := b.handlers[event.EventType()]
+	b.mutex.RUnlock()
+
+	fail := []string{}
+	for _, handler := range handlers {
+		// Other handlers still get the event
+		if err := handler(event); err != nil {
+			fail = append(fail, err.Error())
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Handling %s failed: %s", event.EventType(), strings.Join(fail, ", "))
+	}
+	return nil
+}
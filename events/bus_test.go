@@ -0,0 +1,53 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishToSubscribers(t *testing.T) {
+	bus := NewInProcessBus()
+	received := []Event{}
+	bus.Subscribe(TypeBookLent, func(event Event) error {
+		received = append(received, event)
+		return nil
+	})
+	bus.Subscribe(TypeBookLent, func(event Event) error {
+		received = append(received, event)
+		return nil
+	})
+	bus.Subscribe(TypeLendRejected, func(event Event) error {
+		t.Error("Handler of other event type called")
+		return nil
+	})
+
+	event := &BookLent{CustomerID: 1, BookID: "12345"}
+	assert.Nil(t, bus.Publish(event))
+	assert.Equal(t, []Event{event, event}, received)
+
+	assert.Nil(t, bus.Publish(&FeeCollected{}))
+}
+
+func TestPublishHandlersFail(t *testing.T) {
+	bus := NewInProcessBus()
+	called := 0
+	bus.Subscribe(TypeFeeCollected, func(event Event) error {
+		called++
+		return fmt.Errorf("Accounting down")
+	})
+	bus.Subscribe(TypeFeeCollected, func(event Event) error {
+		called++
+		return nil
+	})
+	bus.Subscribe(TypeFeeCollected, func(event Event) error {
+		called++
+		return fmt.Errorf("Timeout")
+	})
+
+	err := bus.Publish(&FeeCollected{})
+	assert.Error(t, err)
+	assert.Equal(t, "Handling fee-collected failed: Accounting down, Timeout", err.Error())
+	assert.Equal(t, 3, called)
+}
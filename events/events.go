@@ -0,0 +1,95 @@
+package events
+
+import "time"
+
+// Type name of a domain event, used for routing and persisting
+type Type string
+
+// Events published by lending operations
+const (
+	TypeBookLent     Type = "book-lent"
+	TypeLendRenewed  Type = "lend-renewed"
+	TypeFeeCollected Type = "fee-collected"
+	TypeLendRejected Type = "lend-rejected"
+	TypeBookReturned Type = "book-returned"
+)
+
+// AllTypes every event type, for subscribers interested in everything
+var AllTypes = []Type{TypeBookLent, TypeLendRenewed, TypeFeeCollected, TypeLendRejected, TypeBookReturned}
+
+// Event something that happened in the lending domain
+type Event interface {
+	EventType() Type
+}
+
+// BookLent a book was lent to a customer
+type BookLent struct {
+	Time             time.Time
+	CustomerID       int
+	BookID           string
+	LatestReturnDate time.Time
+	PerformedBy      string
+}
+
+// EventType book-lent
+func (e *BookLent) EventType() Type { return TypeBookLent }
+
+// LendRenewed the latest return date of a lend was extended
+type LendRenewed struct {
+	Time             time.Time
+	CustomerID       int
+	BookID           string
+	LatestReturnDate time.Time
+	Renewals         int
+	PerformedBy      string
+}
+
+// EventType lend-renewed
+func (e *LendRenewed) EventType() Type { return TypeLendRenewed }
+
+// FeeCollected late fees were paid, by the customer or a guardian
+type FeeCollected struct {
+	Time       time.Time
+	CustomerID int
+	PayerID    int
+	BookIDs    []string
+	Amount     int
+}
+
+// EventType fee-collected
+func (e *FeeCollected) EventType() Type { return TypeFeeCollected }
+
+// LendRejected lending or renewing a book failed
+type LendRejected struct {
+	Time       time.Time
+	CustomerID int
+	BookID     string
+	// Error code of the failure, for the customer facing message
+	Code string
+	// Customer facing message, never internal details
+	Reason      string
+	PerformedBy string
+}
+
+// EventType lend-rejected
+func (e *LendRejected) EventType() Type { return TypeLendRejected }
+
+// BookReturned a lent book was returned
+type BookReturned struct {
+	Time       time.Time
+	CustomerID int
+	BookID     string
+	DaysLate   int
+}
+
+// EventType book-returned
+func (e *BookReturned) EventType() Type { return TypeBookReturned }
+
+// Events by type, for decoding persisted events
+var eventFactories = map[Type]func() Event{
+	TypeBookLent:     func() Event { return &BookLent{} },
+	TypeLendRenewed:  func() Event { return &LendRenewed{} },
+	TypeFeeCollected: func() Event { return &FeeCollected{} },
+	TypeLendRejected: func() Event { return &LendRejected{} },
+	TypeBookReturned: func() Event { return &BookReturned{} },
+}
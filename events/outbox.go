@@ -0,0 +1,100 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// Outbox publisher storing every event before publishing it, so events are not lost when publishing fails
+type Outbox struct {
+	Clock func() time.Time
+
+	store     servicelib.OutboxStore
+	publisher Publisher
+}
+
+// NewOutbox outbox storing events in store and publishing them to publisher
+func NewOutbox(store servicelib.OutboxStore, publisher Publisher) *Outbox {
+	return &Outbox{Clock: time.Now, store: store, publisher: publisher}
+}
+
+// Publish stores event and publishes it, an event that fails to publish stays in the outbox for Relay
+func (o *Outbox) Publish(event Event) error {
+	message, err := o.createMessage(event)
+	if err != nil {
+		return err
+	}
+
+	if err := o.store.AddOutboxMessage(message); err != nil {
+		return errors.Wrapf(err, "Storing %s failed", event.EventType())
+	}
+
+	// Relayed later
+	_ = o.deliver(message, event)
+	return nil
+}
+
+// Relay publishes every event still in the outbox, meant to run periodically
+func (o *Outbox) Relay() error {
+	messages, err := o.store.GetOutboxMessages()
+	if err != nil {
+		return errors.Wrap(err, "Cannot retrieve outbox")
+	}
+
+	fail := []string{}
+	for _, message := range messages {
+		// Must be relayed again later
+		if err := o.relayMessage(message); err != nil {
+			fail = append(fail, strconv.Itoa(message.ID))
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Relaying events failed for messages %s", strings.Join(fail, ", "))
+	}
+	return nil
+}
+
+func (o *Outbox) relayMessage(message *servicelib.OutboxMessage) error {
+	event, err := decodeEvent(message)
+	if err != nil {
+		return err
+	}
+
+	return o.deliver(message, event)
+}
+
+func (o *Outbox) deliver(message *servicelib.OutboxMessage, event Event) error {
+	if err := o.publisher.Publish(event); err != nil {
+		return err
+	}
+
+	return o.store.RemoveOutboxMessage(message.ID)
+}
+
+func (o *Outbox) createMessage(event Event) (*servicelib.OutboxMessage, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Encoding %s failed", event.EventType())
+	}
+
+	return &servicelib.OutboxMessage{Type: string(event.EventType()), Payload: payload, CreatedAt: o.Clock()}, nil
+}
+
+func decodeEvent(message *servicelib.OutboxMessage) (Event, error) {
+	factory, ok := eventFactories[Type(message.Type)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown event type %q", message.Type)
+	}
+
+	event := factory()
+	if err := json.Unmarshal(message.Payload, event); err != nil {
+		return nil, errors.Wrapf(err, "Decoding %s failed", message.Type)
+	}
+	return event, nil
+}
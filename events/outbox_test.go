@@ -0,0 +1,94 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (p *mockPublisher) Publish(event Event) error {
+	return p.Called(event).Error(0)
+}
+
+var now = time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+func TestOutboxPublishes(t *testing.T) {
+	event := &BookLent{Time: now, CustomerID: 1, BookID: "12345", LatestReturnDate: now.AddDate(0, 0, 7), PerformedBy: "ola"}
+
+	store := new(mocks.OutboxStore)
+	store.On("AddOutboxMessage", &servicelib.OutboxMessage{
+		Type:      "book-lent",
+		Payload:   []byte(`{"Time":"2019-09-09T12:00:00Z","CustomerID":1,"BookID":"12345","LatestReturnDate":"2019-09-16T12:00:00Z","PerformedBy":"ola"}`),
+		CreatedAt: now,
+	}).Run(func(args mock.Arguments) {
+		args.Get(0).(*servicelib.OutboxMessage).ID = 7
+	}).Return(nil)
+	store.On("RemoveOutboxMessage", 7).Return(nil)
+	publisher := new(mockPublisher)
+	publisher.On("Publish", event).Return(nil)
+
+	outbox := NewOutbox(store, publisher)
+	outbox.Clock = func() time.Time { return now }
+	assert.Nil(t, outbox.Publish(event))
+
+	store.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestOutboxKeepsEventWhenPublishingFails(t *testing.T) {
+	event := &FeeCollected{Time: now, CustomerID: 1, PayerID: 2, BookIDs: []string{"12345"}, Amount: 20}
+
+	store := new(mocks.OutboxStore)
+	store.On("AddOutboxMessage", mock.AnythingOfType("*servicelib.OutboxMessage")).Return(nil)
+	publisher := new(mockPublisher)
+	publisher.On("Publish", event).Return(fmt.Errorf("Broker down"))
+
+	assert.Nil(t, NewOutbox(store, publisher).Publish(event))
+
+	store.AssertExpectations(t)
+	store.AssertNotCalled(t, "RemoveOutboxMessage", mock.Anything)
+	publisher.AssertExpectations(t)
+}
+
+func TestOutboxStoringFails(t *testing.T) {
+	store := new(mocks.OutboxStore)
+	store.On("AddOutboxMessage", mock.AnythingOfType("*servicelib.OutboxMessage")).Return(fmt.Errorf("DB error"))
+	publisher := new(mockPublisher)
+
+	err := NewOutbox(store, publisher).Publish(&LendRejected{})
+	assert.Error(t, err)
+	assert.Equal(t, "Storing lend-rejected failed: DB error", err.Error())
+
+	store.AssertExpectations(t)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	store := new(mocks.OutboxStore)
+	store.On("GetOutboxMessages").Return([]*servicelib.OutboxMessage{
+		{ID: 1, Type: "lend-renewed", Payload: []byte(`{"CustomerID":1,"BookID":"12345","Renewals":2}`)},
+		{ID: 2, Type: "lend-rejected", Payload: []byte(`{"CustomerID":2,"Code":"book-not-found"}`)},
+		{ID: 3, Type: "book-lost", Payload: []byte(`{}`)},
+		{ID: 4, Type: "book-lent", Payload: []byte(`not json`)},
+	}, nil)
+	store.On("RemoveOutboxMessage", 1).Return(nil)
+	publisher := new(mockPublisher)
+	publisher.On("Publish", &LendRenewed{CustomerID: 1, BookID: "12345", Renewals: 2}).Return(nil)
+	publisher.On("Publish", &LendRejected{CustomerID: 2, Code: "book-not-found"}).Return(fmt.Errorf("Broker down"))
+
+	err := NewOutbox(store, publisher).Relay()
+	assert.Error(t, err)
+	assert.Equal(t, "Relaying events failed for messages 2, 3, 4", err.Error())
+
+	store.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
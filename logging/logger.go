@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Level severity of a log entry
+type Level int
+
+// Levels from least to most severe
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Redacted written in place of personal data
+const Redacted = "[redacted]"
+
+// Field named value attached to a log entry
+type Field struct {
+	Key   string
+	Value interface{}
+	// Personal data about a customer, redacted unless the logger is told otherwise
+	PII bool
+}
+
+// BookID field identifying a book
+func BookID(bookID string) Field {
+	return Field{Key: "bookId", Value: bookID}
+}
+
+// CustomerID field identifying a customer, the ID itself is not personal data
+func CustomerID(customerID int) Field {
+	return Field{Key: "customerId", Value: customerID}
+}
+
+// Int field with a number
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String field with a text
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool field with a flag
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Error field with the message of err
+func Error(err error) Field {
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// PII field with personal data about a customer, like age or contact details
+func PII(key string, value interface{}) Field {
+	return Field{Key: key, Value: value, PII: true}
+}
+
+// Logger writes structured log entries
+type Logger interface {
+	Log(level Level, message string, fields ...Field)
+}
+
+// JSONLogger logger writing one JSON object per entry, personal data is redacted by default
+type JSONLogger struct {
+	// Entries below are dropped
+	MinLevel Level
+	// Writes personal data as is, only for environments allowed to hold it
+	ShowPII bool
+	Clock   func() time.Time
+
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// NewJSONLogger logger writing info and above to w
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{MinLevel: LevelInfo, Clock: time.Now, w: w}
+}
+
+// Log writes entry as a single line, fields are written after time, level and message
+func (l *JSONLogger) Log(level Level, message string, fields ...Field) {
+	if level < l.MinLevel {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Nowhere to report a failing log
+	_, _ = l.w.Write(append(encodeEntry(l.Clock(), level, message, redact(fields, l.ShowPII)), '\n'))
+}
+
+func encodeEntry(t time.Time, level Level, message string, fields []Field) []byte {
+	entry := []byte{'{'}
+	entry = appendPair(entry, "time", t.Format(time.RFC3339Nano))
+	entry = append(entry, ',')
+	entry = appendPair(entry, "level", level.String())
+	entry = append(entry, ',')
+	entry = appendPair(entry, "message", message)
+	for _, field := range fields {
+		entry = append(entry, ',')
+		entry = appendPair(entry, field.Key, field.Value)
+	}
+	return append(entry, '}')
+}
+
+// Keeps the fields in the order they were logged, a map would sort them
+func appendPair(entry []byte, key string, value interface{}) []byte {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(err.Error())
+	}
+	entry = append(entry, k...)
+	entry = append(entry, ':')
+	return append(entry, v...)
+}
+
+func redact(fields []Field, showPII bool) []Field {
+	if showPII {
+		return fields
+	}
+
+	redacted := make([]Field, len(fields))
+	for i, field := range fields {
+		if field.PII {
+			field.Value = Redacted
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+// Entry log entry kept by RecordingLogger
+type Entry struct {
+	Level   Level
+	Message string
+	Fields  []Field
+}
+
+// Field value of the field with key, nil if the entry has none
+func (e *Entry) Field(key string) interface{} {
+	for _, field := range e.Fields {
+		if field.Key == key {
+			return field.Value
+		}
+	}
+	return nil
+}
+
+// RecordingLogger fake logger keeping every entry with personal data redacted, for tests
+type RecordingLogger struct {
+	Entries []*Entry
+	mutex   sync.Mutex
+}
+
+// Log records entry
+func (l *RecordingLogger) Log(level Level, message string, fields ...Field) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.Entries = append(l.Entries, &Entry{Level: level, Message: message, Fields: redact(fields, false)})
+}
+
+// Find first entry with message, nil if there is none
+func (l *RecordingLogger) Find(message string) *Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, entry := range l.Entries {
+		if entry.Message == message {
+			return entry
+		}
+	}
+	return nil
+}
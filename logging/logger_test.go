@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+func TestJSONLoggerRedactsPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf)
+	logger.Clock = func() time.Time { return now }
+
+	logger.Log(LevelWarn, "Payment blocked", CustomerID(1), PII("age", 11), Int("books", 2))
+	logger.Log(LevelError, "Saving lend failed", BookID("12345"), Error(fmt.Errorf("DB error")))
+
+	assert.Equal(t, `{"time":"2019-09-09T12:00:00Z","level":"warn","message":"Payment blocked","customerId":1,"age":"[redacted]","books":2}
+{"time":"2019-09-09T12:00:00Z","level":"error","message":"Saving lend failed","bookId":"12345","error":"DB error"}
+`, buf.String())
+}
+
+func TestJSONLoggerShowPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf)
+	logger.Clock = func() time.Time { return now }
+	logger.ShowPII = true
+
+	logger.Log(LevelInfo, "Payment blocked", PII("age", 11), Bool("guardian", false), String("tier", "child"))
+
+	assert.Equal(t, `{"time":"2019-09-09T12:00:00Z","level":"info","message":"Payment blocked","age":11,"guardian":false,"tier":"child"}
+`, buf.String())
+}
+
+func TestJSONLoggerMinLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf)
+
+	logger.Log(LevelDebug, "Lending limit checked")
+	assert.Empty(t, buf.String())
+
+	logger.MinLevel = LevelDebug
+	logger.Log(LevelDebug, "Lending limit checked")
+	assert.Contains(t, buf.String(), `"level":"debug","message":"Lending limit checked"`)
+}
+
+func TestRecordingLoggerRedactsPII(t *testing.T) {
+	logger := &RecordingLogger{}
+	logger.Log(LevelWarn, "Payment blocked", CustomerID(1), PII("age", 11))
+
+	entry := logger.Find("Payment blocked")
+	assert.Equal(t, LevelWarn, entry.Level)
+	assert.Equal(t, 1, entry.Field("customerId"))
+	assert.Equal(t, Redacted, entry.Field("age"))
+	assert.Nil(t, entry.Field("bookId"))
+	assert.Nil(t, logger.Find("Lend failed"))
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// Outcomes of LendBook
+const (
+	OutcomeLent     = "lent"
+	OutcomeRenewed  = "renewed"
+	OutcomeRejected = "rejected"
+)
+
+// LendingMetrics counts lending outcomes from the events published by LendBook
+type LendingMetrics struct {
+	Outcomes      *Counter
+	Rejections    *Counter
+	FeesCollected *Counter
+}
+
+// NewLendingMetrics lending metrics registered in registry
+func NewLendingMetrics(registry *Registry) *LendingMetrics {
+	return &LendingMetrics{
+		Outcomes:      registry.NewCounter("slap_lend_outcomes_total", "Lend and renewal attempts by outcome.", "outcome"),
+		Rejections:    registry.NewCounter("slap_lend_rejections_total", "Rejected lends and renewals by error code.", "code"),
+		FeesCollected: registry.NewCounter("slap_fees_collected_total", "Late fees collected."),
+	}
+}
+
+// SubscribeTo counts the lending events published on bus
+func (m *LendingMetrics) SubscribeTo(bus events.EventBus) {
+	for _, eventType := range []events.Type{events.TypeBookLent, events.TypeLendRenewed, events.TypeLendRejected, events.TypeFeeCollected} {
+		bus.Subscribe(eventType, m.Handle)
+	}
+}
+
+// Handle counts event, events not about lending outcomes are ignored
+func (m *LendingMetrics) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case *events.BookLent:
+		m.Outcomes.Inc(OutcomeLent)
+	case *events.LendRenewed:
+		m.Outcomes.Inc(OutcomeRenewed)
+	case *events.LendRejected:
+		m.Outcomes.Inc(OutcomeRejected)
+		m.Rejections.Inc(getRejectionCode(e))
+	case *events.FeeCollected:
+		m.FeesCollected.Add(float64(e.Amount))
+	}
+	return nil
+}
+
+func getRejectionCode(event *events.LendRejected) string {
+	if event.Code == "" {
+		return "unknown"
+	}
+	return event.Code
+}
+
+// CirculationGauges books currently lent and overdue, updated by walking all customers
+type CirculationGauges struct {
+	ActiveLends  *Gauge
+	OverdueLends *Gauge
+
+	customerLister servicelib.CustomerLister
+	libraryService servicelib.LibraryService
+}
+
+// NewCirculationGauges circulation gauges registered in registry
+func NewCirculationGauges(registry *Registry, customerLister servicelib.CustomerLister, libraryService servicelib.LibraryService) *CirculationGauges {
+	return &CirculationGauges{
+		ActiveLends:    registry.NewGauge("slap_active_lends", "Books currently lent to customers."),
+		OverdueLends:   registry.NewGauge("slap_overdue_lends", "Books lent and not returned by the latest return date."),
+		customerLister: customerLister,
+		libraryService: libraryService,
+	}
+}
+
+// Update counts the lends as of now, meant to run periodically since walking every customer is slow
+func (g *CirculationGauges) Update(now time.Time) error {
+	customers, err := g.customerLister.GetCustomers()
+	if err != nil {
+		return errors.Wrap(err, "Cannot retrieve customers")
+	}
+
+	active, overdue := 0, 0
+	fail := []string{}
+	for _, customer := range customers {
+		bookLends, err := g.libraryService.GetLendsForCustomer(customer.ID)
+		// Other customers are still counted
+		if err != nil {
+			fail = append(fail, err.Error())
+			continue
+		}
+
+		for _, book := range bookLends {
+			if book.CurrentLend == nil {
+				continue
+			}
+			active++
+			if book.CurrentLend.LatestReturnDate.Before(now) {
+				overdue++
+			}
+		}
+	}
+	// Partial counts would look like returns, keep the previous values
+	if len(fail) > 0 {
+		return fmt.Errorf("Cannot retrieve lends: %s", strings.Join(fail, ", "))
+	}
+
+	g.ActiveLends.Set(float64(active))
+	g.OverdueLends.Set(float64(overdue))
+	return nil
+}
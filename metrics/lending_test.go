@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+func TestLendingMetricsCountsEvents(t *testing.T) {
+	bus := events.NewInProcessBus()
+	lendingMetrics := NewLendingMetrics(NewRegistry())
+	lendingMetrics.SubscribeTo(bus)
+
+	assert.Nil(t, bus.Publish(&events.BookLent{}))
+	assert.Nil(t, bus.Publish(&events.BookLent{}))
+	assert.Nil(t, bus.Publish(&events.LendRenewed{}))
+	assert.Nil(t, bus.Publish(&events.LendRejected{Code: "lend-limit-reached"}))
+	assert.Nil(t, bus.Publish(&events.LendRejected{Code: "book-not-found"}))
+	assert.Nil(t, bus.Publish(&events.LendRejected{Code: "book-not-found"}))
+	assert.Nil(t, bus.Publish(&events.LendRejected{}))
+	assert.Nil(t, bus.Publish(&events.FeeCollected{Amount: 20}))
+	assert.Nil(t, bus.Publish(&events.FeeCollected{Amount: 15}))
+	assert.Nil(t, bus.Publish(&events.BookReturned{}))
+
+	assert.Equal(t, float64(2), lendingMetrics.Outcomes.Value(OutcomeLent))
+	assert.Equal(t, float64(1), lendingMetrics.Outcomes.Value(OutcomeRenewed))
+	assert.Equal(t, float64(4), lendingMetrics.Outcomes.Value(OutcomeRejected))
+	assert.Equal(t, float64(1), lendingMetrics.Rejections.Value("lend-limit-reached"))
+	assert.Equal(t, float64(2), lendingMetrics.Rejections.Value("book-not-found"))
+	assert.Equal(t, float64(1), lendingMetrics.Rejections.Value("unknown"))
+	assert.Equal(t, float64(35), lendingMetrics.FeesCollected.Value())
+}
+
+func TestCirculationGauges(t *testing.T) {
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{{ID: 1}, {ID: 2}}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", 1).Return([]*servicelib.Book{
+		{ID: "11111", CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, -1)}},
+		{ID: "22222", CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, 3)}},
+		{ID: "33333"},
+	}, nil)
+	libraryService.On("GetLendsForCustomer", 2).Return([]*servicelib.Book{
+		{ID: "44444", CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, -10)}},
+	}, nil)
+
+	gauges := NewCirculationGauges(NewRegistry(), customerLister, libraryService)
+	assert.Nil(t, gauges.Update(now))
+
+	assert.Equal(t, float64(3), gauges.ActiveLends.Value())
+	assert.Equal(t, float64(2), gauges.OverdueLends.Value())
+}
+
+func TestCirculationGaugesKeepValuesWhenLendsFail(t *testing.T) {
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{{ID: 1}}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", 1).Return(nil, fmt.Errorf("Timeout"))
+
+	gauges := NewCirculationGauges(NewRegistry(), customerLister, libraryService)
+	gauges.ActiveLends.Set(7)
+
+	err := gauges.Update(now)
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot retrieve lends: Timeout", err.Error())
+	assert.Equal(t, float64(7), gauges.ActiveLends.Value())
+}
+
+func TestCirculationGaugesCannotRetrieveCustomers(t *testing.T) {
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return(nil, fmt.Errorf("DB error"))
+
+	err := NewCirculationGauges(NewRegistry(), customerLister, new(mocks.LibraryService)).Update(now)
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot retrieve customers: DB error", err.Error())
+}
@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets upper bounds in seconds for latency histograms
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Registry metrics exposed together in the Prometheus text format
+type Registry struct {
+	metrics []metric
+	mutex   sync.Mutex
+}
+
+// NewRegistry registry without metrics
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter counter registered in the registry, labels are set when counting
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{series: newSeries(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// NewGauge gauge registered in the registry, labels are set when setting a value
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	g := &Gauge{series: newSeries(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// NewHistogram histogram with upper bounds buckets registered in the registry
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogramValue{}}
+	r.register(h)
+	return h
+}
+
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mutex.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP /metrics endpoint for the scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	// Nothing to tell the scraper once the response is started
+	_ = r.WriteText(w)
+}
+
+// Counter value only going up, one per combination of label values
+type Counter struct {
+	*series
+}
+
+// Inc adds one for labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds value for labelValues, value must not be negative
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.update(labelValues, func(current float64) float64 { return current + value })
+}
+
+// Gauge value going up and down, one per combination of label values
+type Gauge struct {
+	*series
+}
+
+// Set value for labelValues
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return value })
+}
+
+type series struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	values     map[string]float64
+	mutex      sync.Mutex
+}
+
+func newSeries(name string, help string, metricType string, labels []string) *series {
+	return &series{name: name, help: help, metricType: metricType, labels: labels, values: map[string]float64{}}
+}
+
+// Value current value for labelValues, 0 if never set
+func (s *series) Value(labelValues ...string) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.values[formatLabels(s.labels, labelValues)]
+}
+
+func (s *series) update(labelValues []string, update func(float64) float64) {
+	key := formatLabels(s.labels, labelValues)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values[key] = update(s.values[key])
+}
+
+func (s *series) write(w io.Writer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := writeHeader(w, s.name, s.help, s.metricType); err != nil {
+		return err
+	}
+	keys := []string{}
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	for _, labels := range sortKeys(keys) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", s.name, labels, formatValue(s.values[labels])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram observations counted in buckets, one per combination of label values
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogramValue
+	mutex   sync.Mutex
+}
+
+type histogramValue struct {
+	labelValues []string
+	// Not cumulative, summed when written
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe counts value for labelValues in the first bucket it fits
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+			break
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+// Count number of observations for labelValues
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	v, ok := h.values[formatLabels(h.labels, labelValues)]
+	if !ok {
+		return 0
+	}
+	return v.count
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	keys := []string{}
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	for _, key := range sortKeys(keys) {
+		if err := h.writeValue(w, h.values[key], key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Histogram) writeValue(w io.Writer, v *histogramValue, labels string) error {
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+
+	cumulative := uint64(0)
+	for i, bound := range h.buckets {
+		cumulative += v.counts[i]
+		le := formatLabels(bucketLabels, append(append([]string{}, v.labelValues...), formatValue(bound)))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, le, cumulative); err != nil {
+			return err
+		}
+	}
+	le := formatLabels(bucketLabels, append(append([]string{}, v.labelValues...), "+Inf"))
+	_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+		h.name, le, v.count, h.name, labels, formatValue(v.sum), h.name, labels, v.count)
+	return err
+}
+
+func writeHeader(w io.Writer, name string, help string, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+	return err
+}
+
+// Labels as written in the exposition, also used as the key of the series
+func formatLabels(labels []string, labelValues []string) string {
+	if len(labels) != len(labelValues) {
+		panic(fmt.Sprintf("Expected %d label values, got %d", len(labels), len(labelValues)))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := []string{}
+	for i, label := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", label, labelValues[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Series are written in the same order on every scrape
+func sortKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
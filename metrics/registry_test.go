@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("lends_total", "Lends by outcome.", "outcome")
+	gauge := registry.NewGauge("active_lends", "Books lent.")
+	histogram := registry.NewHistogram("call_seconds", "Call latency.", []float64{0.1, 1}, "method")
+
+	counter.Inc("rejected")
+	counter.Inc("lent")
+	counter.Add(2, "lent")
+	gauge.Set(12)
+	gauge.Set(10)
+	histogram.Observe(0.05, "GetBook")
+	histogram.Observe(0.5, "GetBook")
+	histogram.Observe(3, "GetBook")
+	histogram.Observe(0.1, "SaveBook")
+
+	var buf bytes.Buffer
+	assert.Nil(t, registry.WriteText(&buf))
+	assert.Equal(t, `# HELP lends_total Lends by outcome.
+# TYPE lends_total counter
+lends_total{outcome="lent"} 3
+lends_total{outcome="rejected"} 1
+# HELP active_lends Books lent.
+# TYPE active_lends gauge
+active_lends 10
+# HELP call_seconds Call latency.
+# TYPE call_seconds histogram
+call_seconds_bucket{method="GetBook",le="0.1"} 1
+call_seconds_bucket{method="GetBook",le="1"} 2
+call_seconds_bucket{method="GetBook",le="+Inf"} 3
+call_seconds_sum{method="GetBook"} 3.55
+call_seconds_count{method="GetBook"} 3
+call_seconds_bucket{method="SaveBook",le="0.1"} 1
+call_seconds_bucket{method="SaveBook",le="1"} 1
+call_seconds_bucket{method="SaveBook",le="+Inf"} 1
+call_seconds_sum{method="SaveBook"} 0.1
+call_seconds_count{method="SaveBook"} 1
+`, buf.String())
+
+	assert.Equal(t, float64(3), counter.Value("lent"))
+	assert.Equal(t, float64(0), counter.Value("renewed"))
+	assert.Equal(t, uint64(3), histogram.Count("GetBook"))
+}
+
+func TestWriteTextEscapesLabelValues(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("errors_total", "Errors.", "reason").Inc("Book \"12345\"\nnot found")
+
+	var buf bytes.Buffer
+	assert.Nil(t, registry.WriteText(&buf))
+	assert.Contains(t, buf.String(), `errors_total{reason="Book \"12345\"\nnot found"} 1`)
+}
+
+func TestWrongNumberOfLabelValues(t *testing.T) {
+	counter := NewRegistry().NewCounter("lends_total", "Lends by outcome.", "outcome")
+	assert.Panics(t, func() { counter.Inc() })
+	assert.Panics(t, func() { counter.Inc("lent", "adult") })
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGauge("overdue_lends", "Books overdue.").Set(4)
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "# HELP overdue_lends Books overdue.\n# TYPE overdue_lends gauge\noverdue_lends 4\n", recorder.Body.String())
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// InstrumentedLibraryService records the latency of every call to the wrapped library service
+type InstrumentedLibraryService struct {
+	Clock func() time.Time
+
+	next    servicelib.LibraryService
+	latency *Histogram
+}
+
+// NewInstrumentedLibraryService wraps libraryService, latencies are registered in registry
+func NewInstrumentedLibraryService(registry *Registry, libraryService servicelib.LibraryService) *InstrumentedLibraryService {
+	return &InstrumentedLibraryService{
+		Clock:   time.Now,
+		next:    libraryService,
+		latency: registry.NewHistogram("slap_library_service_duration_seconds", "Latency of library service calls by method.", DefaultBuckets, "method"),
+	}
+}
+
+func (s *InstrumentedLibraryService) observe(method string, start time.Time) {
+	s.latency.Observe(s.Clock().Sub(start).Seconds(), method)
+}
+
+// GetBook book with ID from the wrapped service
+func (s *InstrumentedLibraryService) GetBook(bookID string) *servicelib.Book {
+	defer s.observe("GetBook", s.Clock())
+	return s.next.GetBook(bookID)
+}
+
+// GetOldDbBooks books from the old database of the wrapped service
+func (s *InstrumentedLibraryService) GetOldDbBooks() []*servicelib.Book {
+	defer s.observe("GetOldDbBooks", s.Clock())
+	return s.next.GetOldDbBooks()
+}
+
+// GetCustomer customer with ID from the wrapped service
+func (s *InstrumentedLibraryService) GetCustomer(customerID int) (*servicelib.Customer, error) {
+	defer s.observe("GetCustomer", s.Clock())
+	return s.next.GetCustomer(customerID)
+}
+
+// GetLendsForCustomer books lent to customer from the wrapped service
+func (s *InstrumentedLibraryService) GetLendsForCustomer(customerID int) ([]*servicelib.Book, error) {
+	defer s.observe("GetLendsForCustomer", s.Clock())
+	return s.next.GetLendsForCustomer(customerID)
+}
+
+// CollectPayment collects amount from customer through the wrapped service
+func (s *InstrumentedLibraryService) CollectPayment(customerID int, amount int) error {
+	defer s.observe("CollectPayment", s.Clock())
+	return s.next.CollectPayment(customerID, amount)
+}
+
+// SaveBook saves book through the wrapped service
+func (s *InstrumentedLibraryService) SaveBook(book *servicelib.Book) error {
+	defer s.observe("SaveBook", s.Clock())
+	return s.next.SaveBook(book)
+}
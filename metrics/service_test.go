@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentedLibraryServiceRecordsLatency(t *testing.T) {
+	book := &servicelib.Book{ID: "12345"}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", "12345").Return(book)
+	libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{})
+	libraryService.On("GetCustomer", 1).Return(&servicelib.Customer{ID: 1}, nil)
+	libraryService.On("GetLendsForCustomer", 1).Return([]*servicelib.Book{}, nil)
+	libraryService.On("CollectPayment", 1, 20).Return(fmt.Errorf("Card declined"))
+	libraryService.On("SaveBook", book).Return(nil)
+
+	registry := NewRegistry()
+	instrumented := NewInstrumentedLibraryService(registry, libraryService)
+	// Every call takes 30ms
+	tick := now
+	instrumented.Clock = func() time.Time {
+		tick = tick.Add(30 * time.Millisecond)
+		return tick
+	}
+
+	assert.Equal(t, book, instrumented.GetBook("12345"))
+	assert.Empty(t, instrumented.GetOldDbBooks())
+	customer, err := instrumented.GetCustomer(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, customer.ID)
+	_, err = instrumented.GetLendsForCustomer(1)
+	assert.Nil(t, err)
+	assert.Error(t, instrumented.CollectPayment(1, 20))
+	assert.Nil(t, instrumented.SaveBook(book))
+	assert.Nil(t, instrumented.SaveBook(book))
+
+	for _, method := range []string{"GetBook", "GetOldDbBooks", "GetCustomer", "GetLendsForCustomer", "CollectPayment"} {
+		assert.Equal(t, uint64(1), instrumented.latency.Count(method), method)
+	}
+	assert.Equal(t, uint64(2), instrumented.latency.Count("SaveBook"))
+	libraryService.AssertExpectations(t)
+}
@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+
+// AuditLog is an autogenerated mock type for the AuditLog type
+type AuditLog struct {
+	mock.Mock
+}
+
+// RecordAudit provides a mock function with given fields: _a0
+func (_m *AuditLog) RecordAudit(_a0 *servicelib.AuditEntry) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*servicelib.AuditEntry) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
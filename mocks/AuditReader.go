@@ -0,0 +1,35 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+import time "time"
+
+// AuditReader is an autogenerated mock type for the AuditReader type
+type AuditReader struct {
+	mock.Mock
+}
+
+// GetAuditEntries provides a mock function with given fields: _a0, _a1
+func (_m *AuditReader) GetAuditEntries(_a0 time.Time, _a1 time.Time) ([]*servicelib.AuditEntry, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*servicelib.AuditEntry
+	if rf, ok := ret.Get(0).(func(time.Time, time.Time) []*servicelib.AuditEntry); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*servicelib.AuditEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time, time.Time) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,34 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+
+// CustomerLister is an autogenerated mock type for the CustomerLister type
+type CustomerLister struct {
+	mock.Mock
+}
+
+// GetCustomers provides a mock function with given fields:
+func (_m *CustomerLister) GetCustomers() ([]*servicelib.Customer, error) {
+	ret := _m.Called()
+
+	var r0 []*servicelib.Customer
+	if rf, ok := ret.Get(0).(func() []*servicelib.Customer); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*servicelib.Customer)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+
+// CustomerStore is an autogenerated mock type for the CustomerStore type
+type CustomerStore struct {
+	mock.Mock
+}
+
+// SaveCustomer provides a mock function with given fields: _a0
+func (_m *CustomerStore) SaveCustomer(_a0 *servicelib.Customer) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*servicelib.Customer) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,48 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+
+// Ledger is an autogenerated mock type for the Ledger type
+type Ledger struct {
+	mock.Mock
+}
+
+// AddLedgerEntry provides a mock function with given fields: _a0
+func (_m *Ledger) AddLedgerEntry(_a0 *servicelib.LedgerEntry) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*servicelib.LedgerEntry) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLedgerEntries provides a mock function with given fields: _a0
+func (_m *Ledger) GetLedgerEntries(_a0 int) ([]*servicelib.LedgerEntry, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []*servicelib.LedgerEntry
+	if rf, ok := ret.Get(0).(func(int) []*servicelib.LedgerEntry); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*servicelib.LedgerEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,62 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import servicelib "github.com/eirikbell/slap/servicelib"
+
+// OutboxStore is an autogenerated mock type for the OutboxStore type
+type OutboxStore struct {
+	mock.Mock
+}
+
+// AddOutboxMessage provides a mock function with given fields: _a0
+func (_m *OutboxStore) AddOutboxMessage(_a0 *servicelib.OutboxMessage) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*servicelib.OutboxMessage) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetOutboxMessages provides a mock function with given fields:
+func (_m *OutboxStore) GetOutboxMessages() ([]*servicelib.OutboxMessage, error) {
+	ret := _m.Called()
+
+	var r0 []*servicelib.OutboxMessage
+	if rf, ok := ret.Get(0).(func() []*servicelib.OutboxMessage); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*servicelib.OutboxMessage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveOutboxMessage provides a mock function with given fields: _a0
+func (_m *OutboxStore) RemoveOutboxMessage(_a0 int) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
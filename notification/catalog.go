@@ -0,0 +1,155 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// DefaultLanguage used when customer has no preferred language or no template exists in it
+const DefaultLanguage = "en"
+
+// Data values available to message templates
+type Data struct {
+	CustomerID       int
+	BookID           string
+	LatestReturnDate time.Time
+	// Days until or since the latest return date, or left to pick up a hold
+	Days int
+}
+
+// Template subject and body of a message, rendered with Data
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+type templateKey struct {
+	kind     Kind
+	language string
+	channel  servicelib.Channel
+}
+
+// Catalog message templates per kind, language and channel
+type Catalog struct {
+	templates map[templateKey]*Template
+}
+
+var templateFuncs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+var defaultTexts = []struct {
+	kind     Kind
+	language string
+	subject  string
+	body     string
+	short    string
+}{
+	{KindLendReceipt, "en", `Receipt for book {{.BookID}}`,
+		"You have lent book {{.BookID}}.\nIt must be returned by {{date .LatestReturnDate}}.",
+		`Book {{.BookID}} lent, return by {{date .LatestReturnDate}}.`},
+	{KindLendReceipt, "nb", `Kvittering for bok {{.BookID}}`,
+		"Du har lånt bok {{.BookID}}.\nDen må leveres innen {{date .LatestReturnDate}}.",
+		`Bok {{.BookID}} lånt, lever innen {{date .LatestReturnDate}}.`},
+	{KindDueSoon, "en", `Book {{.BookID}} is due {{date .LatestReturnDate}}`,
+		"Book {{.BookID}} must be returned or renewed within {{.Days}} days.\nRenew online or at the library to avoid late fees.",
+		`Book {{.BookID}} is due {{date .LatestReturnDate}}. Renew online to avoid late fees.`},
+	{KindDueSoon, "nb", `Bok {{.BookID}} skal leveres {{date .LatestReturnDate}}`,
+		"Bok {{.BookID}} må leveres eller fornyes innen {{.Days}} dager.\nForny på nett eller på biblioteket for å unngå gebyr.",
+		`Bok {{.BookID}} skal leveres {{date .LatestReturnDate}}. Forny på nett for å unngå gebyr.`},
+	{KindOverdue, "en", `Book {{.BookID}} is overdue`,
+		"Book {{.BookID}} should have been returned {{date .LatestReturnDate}}, {{.Days}} days ago.\nLate fees are added for every day until the book is returned.",
+		`Book {{.BookID}} should have been returned {{date .LatestReturnDate}}. Late fees are added daily.`},
+	{KindOverdue, "nb", `Bok {{.BookID}} er ikke levert`,
+		"Bok {{.BookID}} skulle vært levert {{date .LatestReturnDate}}, for {{.Days}} dager siden.\nDet legges til gebyr for hver dag til boka er levert.",
+		`Bok {{.BookID}} skulle vært levert {{date .LatestReturnDate}}. Gebyr legges til daglig.`},
+	{KindHoldReady, "en", `Book {{.BookID}} is ready for pickup`,
+		"Book {{.BookID}} you placed on hold is ready for pickup.\nPick it up within {{.Days}} days.",
+		`Book {{.BookID}} is ready for pickup within {{.Days}} days.`},
+	{KindHoldReady, "nb", `Bok {{.BookID}} er klar til henting`,
+		"Bok {{.BookID}} du reserverte er klar til henting.\nHent den innen {{.Days}} dager.",
+		`Bok {{.BookID}} er klar til henting innen {{.Days}} dager.`},
+}
+
+// NewCatalog empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{templates: map[templateKey]*Template{}}
+}
+
+// NewDefaultCatalog catalog with the library's own English and Norwegian templates
+func NewDefaultCatalog() *Catalog {
+	catalog := NewCatalog()
+	for _, text := range defaultTexts {
+		catalog.mustAdd(text.kind, text.language, servicelib.ChannelEmail, text.subject, text.body)
+		catalog.mustAdd(text.kind, text.language, servicelib.ChannelSMS, "", text.short)
+		catalog.mustAdd(text.kind, text.language, servicelib.ChannelPush, text.subject, text.short)
+	}
+	return catalog
+}
+
+// Add parses and registers the template for kind, language and channel, replacing any existing
+func (c *Catalog) Add(kind Kind, language string, channel servicelib.Channel, subject string, body string) error {
+	subjectTemplate, err := template.New("subject").Funcs(templateFuncs).Parse(subject)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid subject for %s %s %s", kind, language, channel)
+	}
+
+	bodyTemplate, err := template.New("body").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid body for %s %s %s", kind, language, channel)
+	}
+
+	c.templates[templateKey{kind, language, channel}] = &Template{Subject: subjectTemplate, Body: bodyTemplate}
+	return nil
+}
+
+func (c *Catalog) mustAdd(kind Kind, language string, channel servicelib.Channel, subject string, body string) {
+	if err := c.Add(kind, language, channel, subject, body); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup template for kind and channel in language, falling back to the default language
+func (c *Catalog) Lookup(kind Kind, language string, channel servicelib.Channel) (*Template, string, error) {
+	if tmpl, ok := c.templates[templateKey{kind, language, channel}]; ok {
+		return tmpl, language, nil
+	}
+
+	if tmpl, ok := c.templates[templateKey{kind, DefaultLanguage, channel}]; ok {
+		return tmpl, DefaultLanguage, nil
+	}
+	return nil, "", fmt.Errorf("No template for %s messages on %s", kind, channel)
+}
+
+// Render message of kind for channel in language
+func (c *Catalog) Render(kind Kind, language string, channel servicelib.Channel, data *Data) (*Message, error) {
+	tmpl, language, err := c.Lookup(kind, language, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := execute(tmpl.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := execute(tmpl.Body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Kind: kind, CustomerID: data.CustomerID, Channel: channel, Language: language, Subject: subject, Body: body}, nil
+}
+
+func execute(tmpl *template.Template, data *Data) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "Rendering message failed")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPerLanguageAndChannel(t *testing.T) {
+	data := &Data{CustomerID: 1, BookID: "11111", LatestReturnDate: time.Date(2019, time.September, 16, 0, 0, 0, 0, time.UTC), Days: 7}
+
+	testCases := []struct {
+		kind             Kind
+		language         string
+		channel          servicelib.Channel
+		expectedLanguage string
+		expectedSubject  string
+		expectedBody     string
+	}{
+		{KindLendReceipt, "en", servicelib.ChannelEmail, "en", "Receipt for book 11111", "You have lent book 11111.\nIt must be returned by 2019-09-16."},
+		{KindLendReceipt, "nb", servicelib.ChannelSMS, "nb", "", "Bok 11111 lånt, lever innen 2019-09-16."},
+		{KindHoldReady, "nb", servicelib.ChannelPush, "nb", "Bok 11111 er klar til henting", "Bok 11111 er klar til henting innen 7 dager."},
+		{KindOverdue, "de", servicelib.ChannelSMS, "en", "", "Book 11111 should have been returned 2019-09-16. Late fees are added daily."},
+	}
+	for _, tt := range testCases {
+		message, err := NewDefaultCatalog().Render(tt.kind, tt.language, tt.channel, data)
+		assert.Nil(t, err)
+		assert.Equal(t, &Message{
+			Kind:       tt.kind,
+			CustomerID: 1,
+			Channel:    tt.channel,
+			Language:   tt.expectedLanguage,
+			Subject:    tt.expectedSubject,
+			Body:       tt.expectedBody,
+		}, message)
+	}
+}
+
+func TestRenderMissingTemplate(t *testing.T) {
+	_, err := NewCatalog().Render(KindHoldReady, "en", servicelib.ChannelEmail, &Data{})
+	assert.Error(t, err)
+	assert.Equal(t, "No template for hold-ready messages on email", err.Error())
+}
+
+func TestAddInvalidTemplate(t *testing.T) {
+	err := NewCatalog().Add(KindHoldReady, "en", servicelib.ChannelEmail, "{{.BookID", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid subject for hold-ready en email")
+}
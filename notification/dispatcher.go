@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// Sender sends a message of kind to customer through the channels customer prefers
+type Sender interface {
+	Send(customer *servicelib.Customer, kind Kind, data *Data) error
+}
+
+// Dispatcher renders messages from the catalog and hands them to the notifier of each channel
+type Dispatcher struct {
+	Catalog   *Catalog
+	notifiers map[servicelib.Channel]Notifier
+}
+
+// NewDispatcher dispatcher rendering from catalog, without any channels registered
+func NewDispatcher(catalog *Catalog) *Dispatcher {
+	return &Dispatcher{Catalog: catalog, notifiers: map[servicelib.Channel]Notifier{}}
+}
+
+// Register notifier delivering messages on channel
+func (d *Dispatcher) Register(channel servicelib.Channel, notifier Notifier) {
+	d.notifiers[channel] = notifier
+}
+
+// Send message of kind to customer on every preferred channel
+func (d *Dispatcher) Send(customer *servicelib.Customer, kind Kind, data *Data) error {
+	fail := []string{}
+	for _, channel := range getPreferredChannels(customer) {
+		// Other channels are still tried
+		if err := d.sendOnChannel(customer, kind, channel, data); err != nil {
+			fail = append(fail, fmt.Sprintf("%s (%s)", channel, err.Error()))
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Sending %s to customer %d failed on %s", kind, customer.ID, strings.Join(fail, ", "))
+	}
+	return nil
+}
+
+func (d *Dispatcher) sendOnChannel(customer *servicelib.Customer, kind Kind, channel servicelib.Channel, data *Data) error {
+	notifier, ok := d.notifiers[channel]
+	if !ok {
+		return fmt.Errorf("no notifier registered")
+	}
+
+	message, err := d.Catalog.Render(kind, getLanguage(customer), channel, data)
+	if err != nil {
+		return err
+	}
+
+	message.Address = getAddress(customer.Contact, channel)
+	return notifier.Notify(message)
+}
+
+func getPreferredChannels(customer *servicelib.Customer) []servicelib.Channel {
+	if len(customer.Contact.Channels) == 0 {
+		return []servicelib.Channel{servicelib.ChannelEmail}
+	}
+	return customer.Contact.Channels
+}
+
+func getLanguage(customer *servicelib.Customer) string {
+	if customer.Contact.Language == "" {
+		return DefaultLanguage
+	}
+	return customer.Contact.Language
+}
+
+func getAddress(contact servicelib.ContactPreferences, channel servicelib.Channel) string {
+	switch channel {
+	case servicelib.ChannelSMS:
+		return contact.Phone
+	case servicelib.ChannelPush:
+		return contact.PushToken
+	default:
+		return contact.Email
+	}
+}
@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendOnPreferredChannels(t *testing.T) {
+	email := &RecordingNotifier{}
+	sms := &RecordingNotifier{}
+	push := &RecordingNotifier{}
+	dispatcher := NewDispatcher(NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, email)
+	dispatcher.Register(servicelib.ChannelSMS, sms)
+	dispatcher.Register(servicelib.ChannelPush, push)
+
+	customer := &servicelib.Customer{ID: 1, Contact: servicelib.ContactPreferences{
+		Email:    "ola@example.com",
+		Phone:    "+4799999999",
+		Channels: []servicelib.Channel{servicelib.ChannelSMS, servicelib.ChannelEmail},
+		Language: "nb",
+	}}
+	data := &Data{CustomerID: 1, BookID: "11111", LatestReturnDate: time.Date(2019, time.September, 16, 0, 0, 0, 0, time.UTC)}
+
+	err := dispatcher.Send(customer, KindLendReceipt, data)
+	assert.Nil(t, err)
+
+	assert.Len(t, sms.Messages, 1)
+	assert.Equal(t, "+4799999999", sms.Messages[0].Address)
+	assert.Equal(t, "nb", sms.Messages[0].Language)
+	assert.Len(t, email.Messages, 1)
+	assert.Equal(t, "ola@example.com", email.Messages[0].Address)
+	assert.Equal(t, "Kvittering for bok 11111", email.Messages[0].Subject)
+	assert.Empty(t, push.Messages)
+}
+
+func TestSendDefaultsToEmail(t *testing.T) {
+	email := &RecordingNotifier{}
+	dispatcher := NewDispatcher(NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, email)
+
+	err := dispatcher.Send(&servicelib.Customer{ID: 1}, KindHoldReady, &Data{CustomerID: 1, BookID: "11111", Days: 7})
+	assert.Nil(t, err)
+
+	assert.Len(t, email.Messages, 1)
+	assert.Equal(t, "en", email.Messages[0].Language)
+}
+
+func TestSendFails(t *testing.T) {
+	email := &RecordingNotifier{Err: fmt.Errorf("SMTP error")}
+	dispatcher := NewDispatcher(NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, email)
+
+	customer := &servicelib.Customer{ID: 1, Contact: servicelib.ContactPreferences{
+		Channels: []servicelib.Channel{servicelib.ChannelPush, servicelib.ChannelEmail},
+	}}
+
+	err := dispatcher.Send(customer, KindHoldReady, &Data{CustomerID: 1, BookID: "11111", Days: 7})
+	assert.Error(t, err)
+	assert.Equal(t, "Sending hold-ready to customer 1 failed on push (no notifier registered), email (SMTP error)", err.Error())
+}
@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// Kind what a message notifies the customer about
+type Kind string
+
+// Messages sent to customers
+const (
+	KindLendReceipt Kind = "lend-receipt"
+	KindDueSoon     Kind = "due-soon"
+	KindOverdue     Kind = "overdue"
+	KindHoldReady   Kind = "hold-ready"
+)
+
+// Message notification to a single customer
+type Message struct {
+	Kind       Kind
+	CustomerID int
+	Channel    servicelib.Channel
+	// Email address, phone number or push token depending on channel
+	Address  string
+	Language string
+	Subject  string
+	Body     string
+}
+
+// Notifier delivers messages to customers through a single channel
+type Notifier interface {
+	Notify(*Message) error
+}
+
+// FileNotifier stand-in for the mail server, appends messages to a local mailbox file
+type FileNotifier struct {
+	Path  string
+	Clock func() time.Time
+	mutex sync.Mutex
+}
+
+// NewFileNotifier notifier writing to the mailbox file at path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path, Clock: time.Now}
+}
+
+// Notify appends message to the mailbox file
+func (n *FileNotifier) Notify(message *Message) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "From library %s\nTo: customer %d\nSubject: %s\n\n%s\n\n",
+		n.Clock().Format(time.ANSIC), message.CustomerID, message.Subject, message.Body)
+	return err
+}
+
+// RecordingNotifier fake backend keeping every message, for tests
+type RecordingNotifier struct {
+	Messages []*Message
+	// Returned from Notify when set
+	Err   error
+	mutex sync.Mutex
+}
+
+// Notify records message
+func (n *RecordingNotifier) Notify(message *Message) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.Err != nil {
+		return n.Err
+	}
+
+	n.Messages = append(n.Messages, message)
+	return nil
+}
@@ -0,0 +1,29 @@
+package notification
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notification")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mailbox")
+	notifier := NewFileNotifier(path)
+	notifier.Clock = func() time.Time { return time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC) }
+
+	assert.Nil(t, notifier.Notify(&Message{Kind: KindOverdue, CustomerID: 1, Subject: "First", Body: "One"}))
+	assert.Nil(t, notifier.Notify(&Message{Kind: KindOverdue, CustomerID: 2, Subject: "Second", Body: "Two"}))
+
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "From library Mon Sep  9 12:00:00 2019\nTo: customer 1\nSubject: First\n\nOne\n\n"+
+		"From library Mon Sep  9 12:00:00 2019\nTo: customer 2\nSubject: Second\n\nTwo\n\n", string(content))
+}
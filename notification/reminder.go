@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// DefaultReminderOffsets days relative to the latest return date when reminders are sent, negative is before
+var DefaultReminderOffsets = []int{-2, 1, 7, 14}
+
+// ReminderScheduler sends reminders about lends soon due or overdue, meant to run once a day
+type ReminderScheduler struct {
+	Offsets []int
+
+	customerLister servicelib.CustomerLister
+	libraryService servicelib.LibraryService
+	sender         Sender
+}
+
+// NewReminderScheduler scheduler with default offsets, sending through sender
+func NewReminderScheduler(customerLister servicelib.CustomerLister, libraryService servicelib.LibraryService, sender Sender) *ReminderScheduler {
+	return &ReminderScheduler{
+		Offsets:        DefaultReminderOffsets,
+		customerLister: customerLister,
+		libraryService: libraryService,
+		sender:         sender,
+	}
+}
+
+// Run sends the reminders due on the day of now
+func (s *ReminderScheduler) Run(now time.Time) error {
+	customers, err := s.customerLister.GetCustomers()
+	if err != nil {
+		return errors.Wrap(err, "Cannot retrieve customers")
+	}
+
+	fail := []string{}
+	for _, customer := range customers {
+		// Must manually notify later
+		if err := s.remindCustomer(customer, now); err != nil {
+			fail = append(fail, strconv.Itoa(customer.ID))
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Sending reminders failed for customers %s", strings.Join(fail, ", "))
+	}
+	return nil
+}
+
+func (s *ReminderScheduler) remindCustomer(customer *servicelib.Customer, now time.Time) error {
+	bookLends, err := s.libraryService.GetLendsForCustomer(customer.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range bookLends {
+		if err := s.remindBookLend(customer, book, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ReminderScheduler) remindBookLend(customer *servicelib.Customer, book *servicelib.Book, now time.Time) error {
+	if book.CurrentLend == nil {
+		return nil
+	}
+
+	days := daysBetween(book.CurrentLend.LatestReturnDate, now)
+	if !s.isReminderDay(days) {
+		return nil
+	}
+
+	kind := KindOverdue
+	if days <= 0 {
+		kind = KindDueSoon
+	}
+
+	data := &Data{
+		CustomerID:       customer.ID,
+		BookID:           book.ID,
+		LatestReturnDate: book.CurrentLend.LatestReturnDate,
+		Days:             abs(days),
+	}
+	return s.sender.Send(customer, kind, data)
+}
+
+func (s *ReminderScheduler) isReminderDay(days int) bool {
+	for _, offset := range s.Offsets {
+		if offset == days {
+			return true
+		}
+	}
+	return false
+}
+
+// Whole calendar days from date to now, negative when date is after now
+func daysBetween(date time.Time, now time.Time) int {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (n *mockNotifier) Notify(message *Message) error {
+	return n.Called(message).Error(0)
+}
+
+func TestRemindersSentOnOffsets(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	dueSoon := &servicelib.Book{ID: "11111", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: time.Date(2019, time.September, 11, 10, 0, 0, 0, time.UTC)}}
+	notDue := &servicelib.Book{ID: "22222", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 3)}}
+	overdue := &servicelib.Book{ID: "33333", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: time.Date(2019, time.September, 2, 18, 0, 0, 0, time.UTC)}}
+	customer := &servicelib.Customer{ID: customerID}
+
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{customer}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{dueSoon, notDue, overdue}, nil)
+	notifier := new(mockNotifier)
+	notifier.On("Notify", &Message{
+		Kind:       KindDueSoon,
+		CustomerID: customerID,
+		Channel:    servicelib.ChannelEmail,
+		Language:   "en",
+		Subject:    "Book 11111 is due 2019-09-11",
+		Body:       "Book 11111 must be returned or renewed within 2 days.\nRenew online or at the library to avoid late fees.",
+	}).Return(nil)
+	notifier.On("Notify", &Message{
+		Kind:       KindOverdue,
+		CustomerID: customerID,
+		Channel:    servicelib.ChannelEmail,
+		Language:   "en",
+		Subject:    "Book 33333 is overdue",
+		Body:       "Book 33333 should have been returned 2019-09-02, 7 days ago.\nLate fees are added for every day until the book is returned.",
+	}).Return(nil)
+	dispatcher := NewDispatcher(NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, notifier)
+
+	err := NewReminderScheduler(customerLister, libraryService, dispatcher).Run(now)
+	assert.Nil(t, err)
+
+	customerLister.AssertExpectations(t)
+	libraryService.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRemindersFail(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	expectedErr := fmt.Errorf("SMTP error")
+
+	customer1 := &servicelib.Customer{ID: 1}
+	customer2 := &servicelib.Customer{ID: 2}
+	customer3 := &servicelib.Customer{ID: 3}
+	overdue := &servicelib.Book{ID: "33333", CurrentLend: &servicelib.Lend{CustomerID: customer2.ID, LatestReturnDate: now.AddDate(0, 0, -1)}}
+
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{customer1, customer2, customer3}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", customer1.ID).Return([]*servicelib.Book{}, nil)
+	libraryService.On("GetLendsForCustomer", customer2.ID).Return([]*servicelib.Book{overdue}, nil)
+	libraryService.On("GetLendsForCustomer", customer3.ID).Return(nil, fmt.Errorf("DB error"))
+	notifier := new(mockNotifier)
+	notifier.On("Notify", mock.AnythingOfType("*notification.Message")).Return(expectedErr)
+	dispatcher := NewDispatcher(NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, notifier)
+
+	err := NewReminderScheduler(customerLister, libraryService, dispatcher).Run(now)
+	assert.Error(t, err)
+	assert.Equal(t, "Sending reminders failed for customers 2, 3", err.Error())
+
+	customerLister.AssertExpectations(t)
+	libraryService.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestDaysBetween(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		date         time.Time
+		expectedDays int
+	}{
+		{time.Date(2019, time.September, 9, 23, 0, 0, 0, time.UTC), 0},
+		{time.Date(2019, time.September, 8, 23, 0, 0, 0, time.UTC), 1},
+		{time.Date(2019, time.September, 11, 0, 0, 0, 0, time.UTC), -2},
+		{time.Date(2019, time.August, 26, 0, 0, 0, 0, time.UTC), 14},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expectedDays, daysBetween(tt.date, now))
+	}
+}
@@ -0,0 +1,143 @@
+package reports
+
+import (
+	"sort"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// DefaultTopBooks how many of the most borrowed books are listed
+const DefaultTopBooks = 10
+
+// unknownBranch label for lends recorded without a branch
+const unknownBranch = "unknown"
+
+// Count number of lends for a day, branch, tier or book
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// CirculationStats aggregate lending statistics for a period
+type CirculationStats struct {
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Lends         int       `json:"lends"`
+	Renewals      int       `json:"renewals"`
+	LendsPerDay   []*Count  `json:"lendsPerDay"`
+	LendsByBranch []*Count  `json:"lendsByBranch"`
+	LendsByTier   []*Count  `json:"lendsByTier"`
+	// Renewals per lend, in percent
+	RenewalRate     int      `json:"renewalRate"`
+	LateReturns     int      `json:"lateReturns"`
+	AverageDaysLate float64  `json:"averageDaysLate"`
+	FeeRevenue      int      `json:"feeRevenue"`
+	FeesCharged     int      `json:"feesCharged"`
+	FeesWaived      int      `json:"feesWaived"`
+	MostBorrowed    []*Count `json:"mostBorrowed"`
+	// Late fees not collected because the customer was younger than 13
+	BlockedPayments      int `json:"blockedPayments"`
+	BlockedPaymentAmount int `json:"blockedPaymentAmount"`
+}
+
+type circulationCounter struct {
+	perDay   map[string]int
+	byBranch map[string]int
+	byTier   map[string]int
+	byBook   map[string]int
+	daysLate int
+}
+
+// Circulation statistics for lending from and including from, until but not including to
+func Circulation(auditReader servicelib.AuditReader, from time.Time, to time.Time, topBooks int) (*CirculationStats, error) {
+	entries, err := auditReader.GetAuditEntries(from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot retrieve audit log")
+	}
+
+	stats := &CirculationStats{From: from, To: to}
+	counter := &circulationCounter{perDay: map[string]int{}, byBranch: map[string]int{}, byTier: map[string]int{}, byBook: map[string]int{}}
+	for _, entry := range entries {
+		addEntry(stats, counter, entry)
+	}
+
+	stats.LendsPerDay = sortByKey(counter.perDay)
+	stats.LendsByBranch = sortByKey(counter.byBranch)
+	stats.LendsByTier = sortByKey(counter.byTier)
+	stats.MostBorrowed = sortByCount(counter.byBook, topBooks)
+	if stats.Lends > 0 {
+		stats.RenewalRate = stats.Renewals * 100 / stats.Lends
+	}
+	if stats.LateReturns > 0 {
+		stats.AverageDaysLate = float64(counter.daysLate) / float64(stats.LateReturns)
+	}
+	return stats, nil
+}
+
+func addEntry(stats *CirculationStats, counter *circulationCounter, entry *servicelib.AuditEntry) {
+	switch entry.Action {
+	case servicelib.AuditActionLend:
+		stats.Lends++
+		counter.perDay[entry.Time.Format("2006-01-02")]++
+		counter.byBranch[getBranch(entry)]++
+		counter.byTier[string(entry.Tier)]++
+		for _, bookID := range entry.BookIDs {
+			counter.byBook[bookID]++
+		}
+	case servicelib.AuditActionRenew:
+		stats.Renewals++
+	case servicelib.AuditActionCollectFee:
+		stats.FeeRevenue += entry.Amount
+		addLateReturns(stats, counter, entry)
+	case servicelib.AuditActionChargeFee:
+		stats.FeesCharged += entry.Amount
+		addLateReturns(stats, counter, entry)
+	case servicelib.AuditActionWaiveFees:
+		stats.FeesWaived += entry.Amount
+	case servicelib.AuditActionPaymentBlocked:
+		stats.BlockedPayments++
+		stats.BlockedPaymentAmount += entry.Amount
+	}
+}
+
+// Fees paid off the ledger later have no books, only fees settled when lending count as late returns
+func addLateReturns(stats *CirculationStats, counter *circulationCounter, entry *servicelib.AuditEntry) {
+	stats.LateReturns += len(entry.BookIDs)
+	counter.daysLate += entry.DaysLate
+}
+
+func getBranch(entry *servicelib.AuditEntry) string {
+	if entry.Branch == "" {
+		return unknownBranch
+	}
+	return entry.Branch
+}
+
+func sortByKey(counts map[string]int) []*Count {
+	sorted := toCounts(counts)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+func sortByCount(counts map[string]int, top int) []*Count {
+	sorted := sortByKey(counts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Count > sorted[j].Count
+	})
+	if len(sorted) > top {
+		return sorted[:top]
+	}
+	return sorted
+}
+
+func toCounts(counts map[string]int) []*Count {
+	list := []*Count{}
+	for key, count := range counts {
+		list = append(list, &Count{Key: key, Count: count})
+	}
+	return list
+}
@@ -0,0 +1,70 @@
+package reports
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCirculation(t *testing.T) {
+	from := time.Date(2019, time.September, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	day1 := time.Date(2019, time.September, 2, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2019, time.September, 3, 10, 0, 0, 0, time.UTC)
+
+	auditReader := new(mocks.AuditReader)
+	auditReader.On("GetAuditEntries", from, to).Return([]*servicelib.AuditEntry{
+		{Action: servicelib.AuditActionLend, Time: day1, CustomerID: 1, BookIDs: []string{"11111"}, Tier: servicelib.TierAdult, Branch: "main"},
+		{Action: servicelib.AuditActionLend, Time: day1, CustomerID: 2, BookIDs: []string{"22222"}, Tier: servicelib.TierChild},
+		{Action: servicelib.AuditActionLend, Time: day2, CustomerID: 3, BookIDs: []string{"11111"}, Tier: servicelib.TierAdult, Branch: "main"},
+		{Action: servicelib.AuditActionLend, Time: day2, CustomerID: 4, BookIDs: []string{"33333"}, Tier: servicelib.TierStudent, Branch: "north"},
+		{Action: servicelib.AuditActionRenew, Time: day2, CustomerID: 1, BookIDs: []string{"11111"}},
+		{Action: servicelib.AuditActionCollectFee, Time: day1, CustomerID: 1, BookIDs: []string{"44444", "55555"}, Amount: 50, DaysLate: 5},
+		{Action: servicelib.AuditActionChargeFee, Time: day2, CustomerID: 3, BookIDs: []string{"66666"}, Amount: 40, DaysLate: 4},
+		{Action: servicelib.AuditActionCollectFee, Time: day2, CustomerID: 3, Amount: 40},
+		{Action: servicelib.AuditActionWaiveFees, Time: day2, CustomerID: 4, BookIDs: []string{"77777"}, Amount: 10},
+		{Action: servicelib.AuditActionPaymentBlocked, Time: day2, CustomerID: 2, BookIDs: []string{"88888"}, Amount: 15, DaysLate: 3},
+		{Action: servicelib.AuditActionExceedLimit, Time: day2, CustomerID: 4},
+	}, nil)
+
+	stats, err := Circulation(auditReader, from, to, 2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, &CirculationStats{
+		From:                 from,
+		To:                   to,
+		Lends:                4,
+		Renewals:             1,
+		LendsPerDay:          []*Count{{"2019-09-02", 2}, {"2019-09-03", 2}},
+		LendsByBranch:        []*Count{{"main", 2}, {"north", 1}, {"unknown", 1}},
+		LendsByTier:          []*Count{{"adult", 2}, {"child", 1}, {"student", 1}},
+		RenewalRate:          25,
+		LateReturns:          3,
+		AverageDaysLate:      3,
+		FeeRevenue:           90,
+		FeesCharged:          40,
+		FeesWaived:           10,
+		MostBorrowed:         []*Count{{"11111", 2}, {"22222", 1}},
+		BlockedPayments:      1,
+		BlockedPaymentAmount: 15,
+	}, stats)
+
+	auditReader.AssertExpectations(t)
+}
+
+func TestCirculationCannotRetrieveAuditLog(t *testing.T) {
+	from := time.Date(2019, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	auditReader := new(mocks.AuditReader)
+	auditReader.On("GetAuditEntries", from, from.AddDate(0, 1, 0)).Return(nil, fmt.Errorf("DB error"))
+
+	_, err := Circulation(auditReader, from, from.AddDate(0, 1, 0), DefaultTopBooks)
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot retrieve audit log: DB error", err.Error())
+
+	auditReader.AssertExpectations(t)
+}
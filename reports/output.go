@@ -0,0 +1,130 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	slap "github.com/eirikbell/slap/slap"
+)
+
+// Table rows of a report section ready for output
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// CustomerTable overdue by customer
+func (r *OverdueReport) CustomerTable() *Table {
+	table := &Table{Header: []string{"Customer", "Overdue books", "Max days late", "Projected fees", "Balance"}}
+	for _, c := range r.Customers {
+		table.Rows = append(table.Rows, []string{strconv.Itoa(c.CustomerID), strconv.Itoa(c.OverdueBooks), strconv.Itoa(c.MaxDaysLate), strconv.Itoa(c.ProjectedFees), strconv.Itoa(c.Balance)})
+	}
+	return table
+}
+
+// BookTable overdue by book
+func (r *OverdueReport) BookTable() *Table {
+	table := &Table{Header: []string{"Book", "Customer", "Latest return date", "Days late", "Projected fee"}}
+	for _, b := range r.Books {
+		table.Rows = append(table.Rows, []string{b.BookID, strconv.Itoa(b.CustomerID), b.LatestReturnDate.Format("2006-01-02"), strconv.Itoa(b.DaysLate), strconv.Itoa(b.ProjectedFee)})
+	}
+	return table
+}
+
+// AgingTable overdue books per aging bucket
+func (r *OverdueReport) AgingTable() *Table {
+	table := &Table{Header: []string{"Overdue", "Books", "Projected fees"}}
+	for _, a := range r.Aging {
+		table.Rows = append(table.Rows, []string{a.Label, strconv.Itoa(a.Books), strconv.Itoa(a.ProjectedFees)})
+	}
+	return table
+}
+
+// SummaryTable totals for the period
+func (s *CirculationStats) SummaryTable() *Table {
+	return &Table{
+		Header: []string{"Statistic", "Value"},
+		Rows: [][]string{
+			{"Lends", strconv.Itoa(s.Lends)},
+			{"Renewals", strconv.Itoa(s.Renewals)},
+			{"Renewal rate", fmt.Sprintf("%d%%", s.RenewalRate)},
+			{"Late returns", strconv.Itoa(s.LateReturns)},
+			{"Average days late", strconv.FormatFloat(s.AverageDaysLate, 'f', 1, 64)},
+			{"Fee revenue", strconv.Itoa(s.FeeRevenue)},
+			{"Fees charged", strconv.Itoa(s.FeesCharged)},
+			{"Fees waived", strconv.Itoa(s.FeesWaived)},
+			{"Blocked payments", strconv.Itoa(s.BlockedPayments)},
+			{"Blocked payment amount", strconv.Itoa(s.BlockedPaymentAmount)},
+		},
+	}
+}
+
+// CountTable counts with key in the named column
+func CountTable(name string, counts []*Count) *Table {
+	table := &Table{Header: []string{name, "Lends"}}
+	for _, c := range counts {
+		table.Rows = append(table.Rows, []string{c.Key, strconv.Itoa(c.Count)})
+	}
+	return table
+}
+
+// DecisionTable rules evaluated when lending, in the order they were evaluated
+func DecisionTable(trace *slap.DecisionTrace) *Table {
+	table := &Table{Header: []string{"Rule", "Result", "Values"}}
+	for _, e := range trace.Evaluations {
+		result := "fail"
+		if e.Passed {
+			result = "pass"
+		}
+		table.Rows = append(table.Rows, []string{string(e.Rule), result, formatValues(e.Values)})
+	}
+	return table
+}
+
+func formatValues(values map[string]interface{}) string {
+	pairs := []string{}
+	for key, value := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// WriteCSV writes table as comma separated values with a header line
+func WriteCSV(w io.Writer, table *Table) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(table.Rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteText writes table as aligned plain text columns
+func WriteText(w io.Writer, table *Table) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(writer, strings.Join(table.Header, "\t")); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		if _, err := fmt.Fprintln(writer, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// WriteJSON writes the whole report or statistics as indented JSON
+func WriteJSON(w io.Writer, report interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
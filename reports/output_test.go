@@ -0,0 +1,75 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	slap "github.com/eirikbell/slap/slap"
+	"github.com/stretchr/testify/assert"
+)
+
+var report = &OverdueReport{
+	GeneratedAt: time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC),
+	Customers: []*OverdueCustomer{
+		{CustomerID: 2, OverdueBooks: 1, MaxDaysLate: 45, ProjectedFees: 225},
+		{CustomerID: 3, Balance: 40},
+	},
+	Books: []*OverdueBook{
+		{BookID: "33333", CustomerID: 2, LatestReturnDate: time.Date(2019, time.July, 26, 12, 0, 0, 0, time.UTC), DaysLate: 45, ProjectedFee: 225},
+	},
+	Aging: []*AgingCount{
+		{AgingBucket: AgingBucket{Label: "1-30 days", MinDays: 1, MaxDays: 30}},
+		{AgingBucket: AgingBucket{Label: "over 30 days", MinDays: 31}, Books: 1, ProjectedFees: 225},
+	},
+	TotalProjectedFees: 225,
+	TotalBalance:       40,
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, report.CustomerTable())
+	assert.Nil(t, err)
+	assert.Equal(t, "Customer,Overdue books,Max days late,Projected fees,Balance\n2,1,45,225,0\n3,0,0,0,40\n", buf.String())
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteText(&buf, report.BookTable())
+	assert.Nil(t, err)
+	assert.Equal(t, "Book   Customer  Latest return date  Days late  Projected fee\n"+
+		"33333  2         2019-07-26          45         225\n", buf.String())
+
+	buf.Reset()
+	err = WriteText(&buf, report.AgingTable())
+	assert.Nil(t, err)
+	assert.Equal(t, "Overdue       Books  Projected fees\n"+
+		"1-30 days     0      0\n"+
+		"over 30 days  1      225\n", buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJSON(&buf, &OverdueReport{GeneratedAt: report.GeneratedAt, Aging: report.Aging[1:], TotalProjectedFees: 225})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"generatedAt": "2019-09-09T12:00:00Z",
+		"customers": null,
+		"books": null,
+		"aging": [{"label": "over 30 days", "minDays": 31, "maxDays": 0, "books": 1, "projectedFees": 225}],
+		"totalProjectedFees": 225,
+		"totalBalance": 0
+	}`, buf.String())
+}
+
+func TestDecisionTable(t *testing.T) {
+	trace := &slap.DecisionTrace{Evaluations: []*slap.RuleEvaluation{
+		{Rule: slap.RuleBookIDValid, Passed: true, Values: map[string]interface{}{"length": 5, "bookId": "12345"}},
+		{Rule: slap.RuleLendingLimit, Passed: false, Values: map[string]interface{}{"lends": 3, "limit": 3, "renewal": false}},
+	}}
+
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, DecisionTable(trace))
+	assert.Nil(t, err)
+	assert.Equal(t, "Rule,Result,Values\nbook-id-valid,pass,bookId=12345 length=5\nlending-limit,fail,lends=3 limit=3 renewal=false\n", buf.String())
+}
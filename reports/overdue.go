@@ -0,0 +1,195 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	slap "github.com/eirikbell/slap/slap"
+	"github.com/pkg/errors"
+)
+
+// AgingBucket range of days overdue, MaxDays 0 means no upper bound
+type AgingBucket struct {
+	Label   string `json:"label"`
+	MinDays int    `json:"minDays"`
+	MaxDays int    `json:"maxDays"`
+}
+
+// DefaultAgingBuckets buckets used unless configured on the generator
+var DefaultAgingBuckets = []AgingBucket{
+	{Label: "1-7 days", MinDays: 1, MaxDays: 7},
+	{Label: "8-14 days", MinDays: 8, MaxDays: 14},
+	{Label: "15-30 days", MinDays: 15, MaxDays: 30},
+	{Label: "31-60 days", MinDays: 31, MaxDays: 60},
+	{Label: "over 60 days", MinDays: 61},
+}
+
+// OverdueBook a single book not returned in time
+type OverdueBook struct {
+	BookID           string    `json:"bookId"`
+	CustomerID       int       `json:"customerId"`
+	LatestReturnDate time.Time `json:"latestReturnDate"`
+	DaysLate         int       `json:"daysLate"`
+	ProjectedFee     int       `json:"projectedFee"`
+}
+
+// OverdueCustomer customer holding overdue books and what the customer owes
+type OverdueCustomer struct {
+	CustomerID    int `json:"customerId"`
+	OverdueBooks  int `json:"overdueBooks"`
+	MaxDaysLate   int `json:"maxDaysLate"`
+	ProjectedFees int `json:"projectedFees"`
+	// Fees already posted on the ledger, 0 without a ledger
+	Balance int `json:"balance"`
+}
+
+// AgingCount overdue books and their projected fees within a bucket
+type AgingCount struct {
+	AgingBucket
+	Books         int `json:"books"`
+	ProjectedFees int `json:"projectedFees"`
+}
+
+// OverdueReport overdue books and outstanding fines at a point in time
+type OverdueReport struct {
+	GeneratedAt        time.Time          `json:"generatedAt"`
+	Customers          []*OverdueCustomer `json:"customers"`
+	Books              []*OverdueBook     `json:"books"`
+	Aging              []*AgingCount      `json:"aging"`
+	TotalProjectedFees int                `json:"totalProjectedFees"`
+	TotalBalance       int                `json:"totalBalance"`
+}
+
+// Generator walks all customers and their lends to build reports
+type Generator struct {
+	Buckets []AgingBucket
+	// Optional, includes posted fees in the report when set
+	Ledger servicelib.Ledger
+
+	customerLister servicelib.CustomerLister
+	libraryService servicelib.LibraryService
+}
+
+// NewGenerator generator with default aging buckets and no ledger
+func NewGenerator(customerLister servicelib.CustomerLister, libraryService servicelib.LibraryService) *Generator {
+	return &Generator{
+		Buckets:        DefaultAgingBuckets,
+		customerLister: customerLister,
+		libraryService: libraryService,
+	}
+}
+
+// Overdue report of books overdue at now, ordered by most days late
+func (g *Generator) Overdue(now time.Time) (*OverdueReport, error) {
+	customers, err := g.customerLister.GetCustomers()
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot retrieve customers")
+	}
+
+	report := &OverdueReport{GeneratedAt: now, Customers: []*OverdueCustomer{}, Books: []*OverdueBook{}, Aging: createAgingCounts(g.Buckets)}
+	fail := []string{}
+	for _, customer := range customers {
+		// A report missing customers would understate what is owed
+		if err := g.addCustomer(report, customer, now); err != nil {
+			fail = append(fail, strconv.Itoa(customer.ID))
+		}
+	}
+	if len(fail) > 0 {
+		return nil, fmt.Errorf("Reading lends failed for customers %s", strings.Join(fail, ", "))
+	}
+
+	sortOverdue(report)
+	return report, nil
+}
+
+func (g *Generator) addCustomer(report *OverdueReport, customer *servicelib.Customer, now time.Time) error {
+	bookLends, err := g.libraryService.GetLendsForCustomer(customer.ID)
+	if err != nil {
+		return err
+	}
+
+	balance, err := g.getBalance(customer)
+	if err != nil {
+		return err
+	}
+
+	overdueBooks := findOverdueBooks(customer, bookLends, now)
+	if len(overdueBooks) == 0 && balance == 0 {
+		return nil
+	}
+
+	row := &OverdueCustomer{
+		CustomerID:    customer.ID,
+		OverdueBooks:  len(overdueBooks),
+		ProjectedFees: slap.GetProjectedFees(customer, bookLends, now),
+		Balance:       balance,
+	}
+	for _, book := range overdueBooks {
+		if book.DaysLate > row.MaxDaysLate {
+			row.MaxDaysLate = book.DaysLate
+		}
+		addToAging(report.Aging, book)
+	}
+
+	report.Customers = append(report.Customers, row)
+	report.Books = append(report.Books, overdueBooks...)
+	report.TotalProjectedFees += row.ProjectedFees
+	report.TotalBalance += row.Balance
+	return nil
+}
+
+func (g *Generator) getBalance(customer *servicelib.Customer) (int, error) {
+	if g.Ledger == nil {
+		return 0, nil
+	}
+	return slap.GetBalance(customer.ID, g.Ledger)
+}
+
+func findOverdueBooks(customer *servicelib.Customer, bookLends []*servicelib.Book, now time.Time) []*OverdueBook {
+	overdueBooks := []*OverdueBook{}
+	for _, book := range bookLends {
+		if book.CurrentLend == nil || !book.CurrentLend.LatestReturnDate.Before(now) {
+			continue
+		}
+
+		overdueBooks = append(overdueBooks, &OverdueBook{
+			BookID:           book.ID,
+			CustomerID:       customer.ID,
+			LatestReturnDate: book.CurrentLend.LatestReturnDate,
+			DaysLate:         slap.GetDaysLate(book, now),
+			ProjectedFee:     slap.GetProjectedFees(customer, []*servicelib.Book{book}, now),
+		})
+	}
+	return overdueBooks
+}
+
+func createAgingCounts(buckets []AgingBucket) []*AgingCount {
+	counts := []*AgingCount{}
+	for _, bucket := range buckets {
+		counts = append(counts, &AgingCount{AgingBucket: bucket})
+	}
+	return counts
+}
+
+func addToAging(counts []*AgingCount, book *OverdueBook) {
+	for _, count := range counts {
+		if book.DaysLate >= count.MinDays && (count.MaxDays == 0 || book.DaysLate <= count.MaxDays) {
+			count.Books++
+			count.ProjectedFees += book.ProjectedFee
+			return
+		}
+	}
+}
+
+func sortOverdue(report *OverdueReport) {
+	sort.SliceStable(report.Customers, func(i, j int) bool {
+		return report.Customers[i].MaxDaysLate > report.Customers[j].MaxDaysLate
+	})
+	sort.SliceStable(report.Books, func(i, j int) bool {
+		return report.Books[i].DaysLate > report.Books[j].DaysLate
+	})
+}
@@ -0,0 +1,93 @@
+package reports
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverdueReport(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	adult := &servicelib.Customer{ID: 1, Age: 30}
+	child := &servicelib.Customer{ID: 2, Age: 10}
+	owing := &servicelib.Customer{ID: 3, Age: 30}
+	onTime := &servicelib.Customer{ID: 4, Age: 30}
+
+	adultBook1 := &servicelib.Book{ID: "11111", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: 1, LatestReturnDate: now.AddDate(0, 0, -3)}}
+	adultBook2 := &servicelib.Book{ID: "22222", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: 1, LatestReturnDate: now.AddDate(0, 0, 2)}}
+	childBook := &servicelib.Book{ID: "33333", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: 2, LatestReturnDate: now.AddDate(0, 0, -45)}}
+	onTimeBook := &servicelib.Book{ID: "44444", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: 4, LatestReturnDate: now.AddDate(0, 0, 1)}}
+
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{adult, child, owing, onTime}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", 1).Return([]*servicelib.Book{adultBook1, adultBook2}, nil)
+	libraryService.On("GetLendsForCustomer", 2).Return([]*servicelib.Book{childBook}, nil)
+	libraryService.On("GetLendsForCustomer", 3).Return([]*servicelib.Book{}, nil)
+	libraryService.On("GetLendsForCustomer", 4).Return([]*servicelib.Book{onTimeBook}, nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", 1).Return([]*servicelib.LedgerEntry{}, nil)
+	ledger.On("GetLedgerEntries", 2).Return([]*servicelib.LedgerEntry{}, nil)
+	ledger.On("GetLedgerEntries", 3).Return([]*servicelib.LedgerEntry{{CustomerID: 3, Type: servicelib.LedgerEntryCharge, Amount: 40}}, nil)
+	ledger.On("GetLedgerEntries", 4).Return([]*servicelib.LedgerEntry{}, nil)
+
+	generator := NewGenerator(customerLister, libraryService)
+	generator.Ledger = ledger
+	report, err := generator.Overdue(now)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []*OverdueCustomer{
+		{CustomerID: 2, OverdueBooks: 1, MaxDaysLate: 45, ProjectedFees: 225},
+		{CustomerID: 1, OverdueBooks: 1, MaxDaysLate: 3, ProjectedFees: 30},
+		{CustomerID: 3, Balance: 40},
+	}, report.Customers)
+	assert.Equal(t, []*OverdueBook{
+		{BookID: "33333", CustomerID: 2, LatestReturnDate: childBook.CurrentLend.LatestReturnDate, DaysLate: 45, ProjectedFee: 225},
+		{BookID: "11111", CustomerID: 1, LatestReturnDate: adultBook1.CurrentLend.LatestReturnDate, DaysLate: 3, ProjectedFee: 30},
+	}, report.Books)
+	assert.Equal(t, 1, report.Aging[0].Books)
+	assert.Equal(t, 30, report.Aging[0].ProjectedFees)
+	assert.Equal(t, 1, report.Aging[3].Books)
+	assert.Equal(t, 225, report.Aging[3].ProjectedFees)
+	assert.Equal(t, 255, report.TotalProjectedFees)
+	assert.Equal(t, 40, report.TotalBalance)
+
+	customerLister.AssertExpectations(t)
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestOverdueReportFails(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return([]*servicelib.Customer{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetLendsForCustomer", 1).Return(nil, fmt.Errorf("DB error"))
+	libraryService.On("GetLendsForCustomer", 2).Return([]*servicelib.Book{}, nil)
+	libraryService.On("GetLendsForCustomer", 3).Return(nil, fmt.Errorf("DB error"))
+
+	report, err := NewGenerator(customerLister, libraryService).Overdue(now)
+	assert.Nil(t, report)
+	assert.Error(t, err)
+	assert.Equal(t, "Reading lends failed for customers 1, 3", err.Error())
+
+	customerLister.AssertExpectations(t)
+	libraryService.AssertExpectations(t)
+}
+
+func TestCannotRetrieveCustomers(t *testing.T) {
+	customerLister := new(mocks.CustomerLister)
+	customerLister.On("GetCustomers").Return(nil, fmt.Errorf("DB error"))
+
+	_, err := NewGenerator(customerLister, new(mocks.LibraryService)).Overdue(time.Now())
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot retrieve customers: DB error", err.Error())
+
+	customerLister.AssertExpectations(t)
+}
@@ -7,20 +7,281 @@ type Lend struct {
 	BookID           string
 	CustomerID       int
 	LatestReturnDate time.Time
+	// Number of times the lend has been renewed
+	Renewals int
+	// Branch the book was lended at
+	LentAt string
+	// Branch the book is to be returned to
+	DueBackAt string
+}
+
+// Hold customer waiting to lend a book
+type Hold struct {
+	CustomerID int
+	PlacedAt   time.Time
+	// Branch the customer picks the book up at, the home branch of the book if not set
+	PickupBranch string
+}
+
+// TransferReason why a book is sent to another branch
+type TransferReason string
+
+// Reasons for transferring books between branches
+const (
+	TransferReasonRequest    TransferReason = "request"
+	TransferReasonHold       TransferReason = "hold"
+	TransferReasonHomeBranch TransferReason = "home-branch"
+)
+
+// Transfer book on its way between branches
+type Transfer struct {
+	From        string
+	To          string
+	Reason      TransferReason
+	RequestedAt time.Time
+	RequestedBy string
 }
 
 // Book unique book in library
 type Book struct {
 	ID          string
 	CurrentLend *Lend
-	DayPenalty  int
+	// Penalty per day late, DefaultDayPenalty for the default of the material type
+	DayPenalty int
+	// Kind of material, a book if not set
+	MaterialType MaterialType
+	// Minimum age for lending the book, 0 if not restricted
+	AgeRating int
+	// Customers waiting for the book, first in line first
+	Holds []*Hold
+	// Branch owning the book
+	HomeBranch string
+	// Branch the book was last seen at, the home branch if not set
+	Location string
+	// Transfer in progress, nil when the book is not in transit
+	Transfer *Transfer
 }
 
+// DefaultDayPenalty day penalty of books charged the default of their material type
+const DefaultDayPenalty = -1
+
+// MaterialType kind of material lended by the library
+type MaterialType string
+
+// Materials in the collection
+const (
+	MaterialBook      MaterialType = "book"
+	MaterialDVD       MaterialType = "dvd"
+	MaterialMagazine  MaterialType = "magazine"
+	MaterialReference MaterialType = "reference"
+	MaterialEquipment MaterialType = "equipment"
+	MaterialEReader   MaterialType = "e-reader"
+)
+
 // Customer unique customer of library
 type Customer struct {
-	ID       int
-	IsLocked bool
-	Age      int
+	ID         int
+	IsLocked   bool
+	LockReason LockReason
+	LockedAt   time.Time
+	// Last time staff lifted a lock on the account
+	UnlockedAt time.Time
+	// Stored age from before birth dates were registered, only used when BirthDate is not set
+	Age       int
+	BirthDate time.Time
+	// Membership tier deciding lending privileges, adult if not set
+	Tier Tier
+	// Customer responsible for a minor, 0 if no guardian is linked
+	GuardianID      int
+	GuardianConsent GuardianConsent
+	Contact         ContactPreferences
+}
+
+// AgeAt age of customer in whole years at the given time
+func (c *Customer) AgeAt(t time.Time) int {
+	if c.BirthDate.IsZero() {
+		return c.Age
+	}
+
+	age := t.Year() - c.BirthDate.Year()
+	if !isBirthdayPassed(c.BirthDate, t) {
+		age--
+	}
+	return age
+}
+
+func isBirthdayPassed(birthDate time.Time, t time.Time) bool {
+	if t.Month() != birthDate.Month() {
+		return t.Month() > birthDate.Month()
+	}
+	return t.Day() >= birthDate.Day()
+}
+
+// LockReason why a customer account is locked
+type LockReason string
+
+// Reasons for locking accounts automatically, accounts locked for other reasons are only unlocked by staff
+const (
+	LockReasonUnpaidFines LockReason = "unpaid fines"
+	LockReasonOverdue     LockReason = "overdue books"
+)
+
+// Tier membership level of customer
+type Tier string
+
+// Membership tiers offered by the library
+const (
+	TierChild         Tier = "child"
+	TierAdult         Tier = "adult"
+	TierStudent       Tier = "student"
+	TierSenior        Tier = "senior"
+	TierStaff         Tier = "staff"
+	TierInstitutional Tier = "institutional"
+)
+
+// Channel way of reaching a customer
+type Channel string
+
+// Channels customers can be notified through
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// ContactPreferences how and in which language customer wants to be contacted
+type ContactPreferences struct {
+	Email     string
+	Phone     string
+	PushToken string
+	// Channels to notify customer through, email if none are chosen
+	Channels []Channel
+	// Language code like "nb" or "en"
+	Language string
+}
+
+// GuardianConsent what the linked guardian has agreed to on behalf of a minor
+type GuardianConsent struct {
+	PayFines  bool
+	ViewLends bool
+	// Minor may lend material rated above their age
+	AgeRestrictedMaterial bool
+}
+
+// LedgerEntryType kind of posting on the account of a customer
+type LedgerEntryType string
+
+// Postings on customer accounts, charges and refunds increase what the customer owes
+const (
+	LedgerEntryCharge  LedgerEntryType = "charge"
+	LedgerEntryPayment LedgerEntryType = "payment"
+	LedgerEntryWaiver  LedgerEntryType = "waiver"
+	LedgerEntryRefund  LedgerEntryType = "refund"
+)
+
+// LedgerEntry single posting on the account of a customer
+type LedgerEntry struct {
+	CustomerID int
+	Type       LedgerEntryType
+	Amount     int
+	Time       time.Time
+	// Books the posting relates to, if any
+	BookIDs []string
+}
+
+// ActorType channel an action is performed through
+type ActorType string
+
+// Channels performing actions in the library
+const (
+	ActorTypeStaff  ActorType = "staff"
+	ActorTypeKiosk  ActorType = "kiosk"
+	ActorTypePortal ActorType = "portal"
+)
+
+// Role set of permissions given to an actor
+type Role string
+
+// Roles given to actors
+const (
+	RoleSelfService   Role = "self-service"
+	RoleLibrarian     Role = "librarian"
+	RoleSupervisor    Role = "supervisor"
+	RoleAdministrator Role = "administrator"
+)
+
+// Actor staff member or system performing an action
+type Actor struct {
+	Type  ActorType
+	ID    string
+	Name  string
+	Roles []Role
+}
+
+// OverrideReason code registered when staff makes an exception from the lending rules
+type OverrideReason string
+
+// Reasons accepted for overrides
+const (
+	OverrideReasonHardship     OverrideReason = "hardship"
+	OverrideReasonLibraryError OverrideReason = "library-error"
+	OverrideReasonSpecialNeeds OverrideReason = "special-needs"
+	OverrideReasonCourseWork   OverrideReason = "course-work"
+)
+
+// AuditAction kind of action recorded for later review
+type AuditAction string
+
+// Actions recorded in the audit log
+const (
+	AuditActionWaiveFees      AuditAction = "waive-fees"
+	AuditActionExceedLimit    AuditAction = "exceed-limit"
+	AuditActionUnlockCustomer AuditAction = "unlock-customer"
+	// Circulation recorded for statistics
+	AuditActionLend           AuditAction = "lend"
+	AuditActionRenew          AuditAction = "renew"
+	AuditActionCollectFee     AuditAction = "collect-fee"
+	AuditActionChargeFee      AuditAction = "charge-fee"
+	AuditActionPaymentBlocked AuditAction = "payment-blocked"
+)
+
+// AuditEntry record of an action for later review
+type AuditEntry struct {
+	Action      AuditAction
+	Time        time.Time
+	CustomerID  int
+	BookIDs     []string
+	Amount      int
+	PerformedBy string
+	ApprovedBy  string
+	Reason      OverrideReason
+	Details     string
+	Tier        Tier
+	// Branch where the action happened, empty when not known
+	Branch string
+	// Days late summed over the books, for fee actions
+	DaysLate int
+}
+
+// OutboxMessage serialized event waiting to be published, ID is assigned by the store
+type OutboxMessage struct {
+	ID        int
+	Type      string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// DeadLetter webhook delivery that failed after all attempts
+type DeadLetter struct {
+	// Unique per delivery, sent again on redelivery so partners can ignore duplicates
+	DeliveryID     string
+	SubscriptionID string
+	URL            string
+	EventType      string
+	Payload        []byte
+	Attempts       int
+	LastError      string
+	Time           time.Time
 }
 
 // LibraryService the sacred service provided by consultants back in the days
@@ -32,3 +293,41 @@ type LibraryService interface {
 	CollectPayment(int, int) error
 	SaveBook(*Book) error
 }
+
+// CustomerStore persistence of customer records added after the sacred service
+type CustomerStore interface {
+	SaveCustomer(*Customer) error
+}
+
+// CustomerLister all customers of the library, for jobs walking every customer
+type CustomerLister interface {
+	GetCustomers() ([]*Customer, error)
+}
+
+// AuditLog trail of actions for later review
+type AuditLog interface {
+	RecordAudit(*AuditEntry) error
+}
+
+// OutboxStore events waiting to be published, persisted alongside the other records
+type OutboxStore interface {
+	AddOutboxMessage(*OutboxMessage) error
+	GetOutboxMessages() ([]*OutboxMessage, error)
+	RemoveOutboxMessage(int) error
+}
+
+// DeadLetterStore deliveries given up on, kept for manual redelivery
+type DeadLetterStore interface {
+	AddDeadLetter(*DeadLetter) error
+}
+
+// AuditReader recorded audit entries, for statistics and review
+type AuditReader interface {
+	GetAuditEntries(time.Time, time.Time) ([]*AuditEntry, error)
+}
+
+// Ledger postings of money customers owe the library
+type Ledger interface {
+	GetLedgerEntries(int) ([]*LedgerEntry, error)
+	AddLedgerEntry(*LedgerEntry) error
+}
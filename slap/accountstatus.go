@@ -0,0 +1,233 @@
+package tldr
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// AccountStatusPolicy limits for automatically locking customer accounts, 0 disables the limit
+type AccountStatusPolicy struct {
+	MaxUnpaidFines int
+	MaxOverdueDays int
+	// Days after staff unlocked an account before it is locked automatically again
+	UnlockGraceDays int
+}
+
+// DefaultAccountStatusPolicy limits used unless configured for the transaction
+var DefaultAccountStatusPolicy = AccountStatusPolicy{
+	MaxUnpaidFines:  500,
+	MaxOverdueDays:  30,
+	UnlockGraceDays: 14,
+}
+
+type accountStatusRule func(customer *servicelib.Customer, bookLends []*servicelib.Book, balance int, now time.Time, policy AccountStatusPolicy) servicelib.LockReason
+
+var accountStatusRules = []accountStatusRule{
+	unpaidFinesRule,
+	overdueBooksRule,
+}
+
+// UpdateAccountStatus locks or unlocks the account of customer according to the account status rules
+func UpdateAccountStatus(customerID int, libraryService servicelib.LibraryService, customerStore servicelib.CustomerStore, options ...LendOption) error {
+	tx := newTransaction(options, WithCustomerStore(customerStore))
+
+	customer, err := libraryService.GetCustomer(customerID)
+	if err != nil {
+		return errors.Wrap(err, "Customer not found")
+	}
+
+	if isManuallyLocked(customer) {
+		return nil
+	}
+
+	bookLends, err := getBookLends(customer, libraryService)
+	if err != nil {
+		return err
+	}
+
+	return updateAccountStatus(tx, customer, bookLends)
+}
+
+// UnlockCustomer lifts any lock on the account of customer
+func UnlockCustomer(actor servicelib.Actor, customerID int, libraryService servicelib.LibraryService, customerStore servicelib.CustomerStore, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor), WithCustomerStore(customerStore))
+	if err := authorize(tx.actor, PermissionUnlockCustomer); err != nil {
+		return err
+	}
+
+	customer, err := libraryService.GetCustomer(customerID)
+	if err != nil {
+		return errors.Wrap(err, "Customer not found")
+	}
+
+	if !setAccountStatus(customer, "", tx.now) {
+		return nil
+	}
+	customer.UnlockedAt = tx.now
+
+	if err := recordUnlock(tx, customer); err != nil {
+		return err
+	}
+
+	return saveAccountStatus(tx, customer)
+}
+
+func recordUnlock(tx *transaction, customer *servicelib.Customer) error {
+	if tx.auditLog == nil {
+		return nil
+	}
+
+	entry := &servicelib.AuditEntry{
+		Action:      servicelib.AuditActionUnlockCustomer,
+		Time:        tx.now,
+		CustomerID:  customer.ID,
+		BookIDs:     []string{},
+		PerformedBy: tx.actor.ID,
+	}
+	if err := tx.auditLog.RecordAudit(entry); err != nil {
+		return errors.Wrap(err, "Recording unlock failed")
+	}
+	return nil
+}
+
+func traceAccountNotLocked(tx *transaction, customer *servicelib.Customer) {
+	traceRule(tx, RuleAccountNotLocked, !customer.IsLocked, map[string]interface{}{"locked": customer.IsLocked, "lockReason": string(customer.LockReason)})
+}
+
+// Fees paid or waived in the transaction may lift an automatic lock, fees charged to the ledger do not
+func defersAutomaticLock(tx *transaction) bool {
+	return tx.ledger == nil || isFeeWaived(tx)
+}
+
+func validateAccountStatus(tx *transaction, customer *servicelib.Customer, bookLends []*servicelib.Book) error {
+	if err := updateAccountStatus(tx, customer, bookLends); err != nil {
+		return err
+	}
+
+	if customer.IsLocked {
+		traceAccountNotLocked(tx, customer)
+		return createAccountLockedError(customer)
+	}
+	return nil
+}
+
+// Eligibility rules only see locks that settling fees in the transaction can not lift
+func withoutAutomaticLock(customer *servicelib.Customer) *servicelib.Customer {
+	if !customer.IsLocked || isManuallyLocked(customer) {
+		return customer
+	}
+
+	c := *customer
+	c.IsLocked = false
+	c.LockReason = ""
+	c.LockedAt = time.Time{}
+	return &c
+}
+
+func updateAccountStatus(tx *transaction, customer *servicelib.Customer, bookLends []*servicelib.Book) error {
+	balance, err := getUnpaidBalance(tx, customer)
+	if err != nil {
+		return err
+	}
+
+	reason := evaluateLockReason(customer, bookLends, balance, tx.now, tx.accountStatusPolicy)
+	if !setAccountStatus(customer, reason, tx.now) {
+		return nil
+	}
+
+	return saveAccountStatus(tx, customer)
+}
+
+func getUnpaidBalance(tx *transaction, customer *servicelib.Customer) (int, error) {
+	if tx.ledger == nil {
+		return 0, nil
+	}
+
+	return GetBalance(customer.ID, tx.ledger)
+}
+
+func evaluateLockReason(customer *servicelib.Customer, bookLends []*servicelib.Book, balance int, now time.Time, policy AccountStatusPolicy) servicelib.LockReason {
+	// Staff lifted the lock, the customer gets time to settle before it is locked again
+	if isInUnlockGrace(customer, now, policy) {
+		return ""
+	}
+
+	for _, rule := range accountStatusRules {
+		if reason := rule(customer, bookLends, balance, now, policy); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func unpaidFinesRule(customer *servicelib.Customer, bookLends []*servicelib.Book, balance int, now time.Time, policy AccountStatusPolicy) servicelib.LockReason {
+	if policy.MaxUnpaidFines == 0 {
+		return ""
+	}
+
+	// Fees posted on the ledger and fees for books still not returned
+	overdueBookLends := filterNotReturnedBookLends(bookLends, now)
+	if balance+calculateTotalPriceForLateReturn(customer, overdueBookLends, now) > policy.MaxUnpaidFines {
+		return servicelib.LockReasonUnpaidFines
+	}
+	return ""
+}
+
+func overdueBooksRule(customer *servicelib.Customer, bookLends []*servicelib.Book, balance int, now time.Time, policy AccountStatusPolicy) servicelib.LockReason {
+	if policy.MaxOverdueDays == 0 {
+		return ""
+	}
+
+	for _, book := range filterNotReturnedBookLends(bookLends, now) {
+		if calculateDaysLate(book, now) > policy.MaxOverdueDays {
+			return servicelib.LockReasonOverdue
+		}
+	}
+	return ""
+}
+
+func isInUnlockGrace(customer *servicelib.Customer, now time.Time, policy AccountStatusPolicy) bool {
+	return !customer.UnlockedAt.IsZero() && now.Before(customer.UnlockedAt.AddDate(0, 0, policy.UnlockGraceDays))
+}
+
+func setAccountStatus(customer *servicelib.Customer, reason servicelib.LockReason, now time.Time) bool {
+	if customer.IsLocked == (reason != "") && customer.LockReason == reason {
+		return false
+	}
+
+	customer.IsLocked = reason != ""
+	customer.LockReason = reason
+	customer.LockedAt = time.Time{}
+	if customer.IsLocked {
+		customer.LockedAt = now
+	}
+	return true
+}
+
+func saveAccountStatus(tx *transaction, customer *servicelib.Customer) error {
+	if tx.customerStore == nil {
+		return nil
+	}
+
+	if err := tx.customerStore.SaveCustomer(customer); err != nil {
+		return errors.Wrap(err, "Saving account status failed")
+	}
+	return nil
+}
+
+func isManuallyLocked(customer *servicelib.Customer) bool {
+	return customer.IsLocked && !isAutomaticLockReason(customer.LockReason)
+}
+
+func isAutomaticLockReason(reason servicelib.LockReason) bool {
+	return reason == servicelib.LockReasonUnpaidFines || reason == servicelib.LockReasonOverdue
+}
+
+func createAccountLockedError(customer *servicelib.Customer) error {
+	if customer.LockReason == "" {
+		return lendingErrorf(CodeAccountLocked, 0, "Customer account is locked")
+	}
+	return lendingErrorf(getAccountLockedCode(customer.LockReason), 0, "Customer account is locked due to %s", customer.LockReason)
+}
@@ -0,0 +1,245 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccountLockedAutomatically(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{{CustomerID: customerID, Type: servicelib.LedgerEntryCharge, Amount: 600}}, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+
+	// The lock is shown rather than the debt limit
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithCustomerStore(customerStore), WithLedger(ledger))
+	assert.Error(t, err)
+	assert.Equal(t, "Customer account is locked due to unpaid fines", err.Error())
+	assert.True(t, customer.IsLocked)
+	assert.Equal(t, servicelib.LockReasonUnpaidFines, customer.LockReason)
+	assert.Equal(t, now, customer.LockedAt)
+	assert.Nil(t, book.CurrentLend)
+
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestOverdueAccountLockedBeforeChargingLedger(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 1}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 1, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -31)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{}, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithCustomerStore(customerStore), WithLedger(ledger))
+	assert.Error(t, err)
+	assert.Equal(t, CodeAccountLockedOverdue, findLendingError(err).Code)
+	assert.Equal(t, now.AddDate(0, 0, -31), nonReturnedBook.CurrentLend.LatestReturnDate)
+
+	libraryService.AssertExpectations(t)
+	ledger.AssertNotCalled(t, "AddLedgerEntry", mock.Anything)
+	libraryService.AssertNotCalled(t, "SaveBook", mock.Anything)
+	customerStore.AssertExpectations(t)
+}
+
+func TestOverdueAccountUnlockedByPayingAtDesk(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -31)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+
+	// Locked by the nightly job
+	err := UpdateAccountStatus(customerID, libraryService, customerStore, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, servicelib.LockReasonOverdue, customer.LockReason)
+
+	// Paying at the desk renews the overdue book and lifts the lock
+	libraryService.On("CollectPayment", customerID, 310).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	err = LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithCustomerStore(customerStore))
+	assert.Nil(t, err)
+	assert.False(t, customer.IsLocked)
+	assert.Equal(t, servicelib.LockReason(""), customer.LockReason)
+	assert.NotNil(t, book.CurrentLend)
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestUnlockByStaffNotUndoneAutomatically(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -31)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30, IsLocked: true, LockReason: servicelib.LockReasonOverdue, LockedAt: now.AddDate(0, 0, -1)}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+
+	err := UnlockCustomer(supervisor, customerID, libraryService, customerStore, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, now, customer.UnlockedAt)
+
+	err = UpdateAccountStatus(customerID, libraryService, customerStore, WithClock(func() time.Time { return now.AddDate(0, 0, 13) }))
+	assert.Nil(t, err)
+	assert.False(t, customer.IsLocked)
+
+	// Still overdue when the grace period is over
+	err = UpdateAccountStatus(customerID, libraryService, customerStore, WithClock(func() time.Time { return now.AddDate(0, 0, 14) }))
+	assert.Nil(t, err)
+	assert.True(t, customer.IsLocked)
+	assert.Equal(t, servicelib.LockReasonOverdue, customer.LockReason)
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestAccountUnlockedAutomatically(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	customer := &servicelib.Customer{ID: customerID, Age: 30, IsLocked: true, LockReason: servicelib.LockReasonOverdue, LockedAt: now.AddDate(0, 0, -2)}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithCustomerStore(customerStore))
+	assert.Nil(t, err)
+	assert.False(t, customer.IsLocked)
+	assert.Equal(t, servicelib.LockReason(""), customer.LockReason)
+	assert.True(t, customer.LockedAt.IsZero())
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestAccountLockedByStaffNotUnlocked(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{}
+	customer := &servicelib.Customer{ID: customerID, IsLocked: true, LockReason: "lost library card"}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, "Customer account is locked due to lost library card", err.Error())
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestSavingAccountStatusFails(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	expectedErr := fmt.Errorf("DB error")
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -40)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30, IsLocked: true, LockReason: servicelib.LockReasonOverdue}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 400).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(expectedErr)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithCustomerStore(customerStore))
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("Saving account status failed: %s", expectedErr.Error()), err.Error())
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestUpdateAccountStatus(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		policy         AccountStatusPolicy
+		expectedReason servicelib.LockReason
+	}{
+		{AccountStatusPolicy{MaxUnpaidFines: 100, MaxOverdueDays: 5}, servicelib.LockReasonUnpaidFines},
+		{AccountStatusPolicy{MaxUnpaidFines: 0, MaxOverdueDays: 5}, servicelib.LockReasonOverdue},
+		{AccountStatusPolicy{MaxUnpaidFines: 0, MaxOverdueDays: 0}, ""},
+	}
+
+	for _, tt := range testCases {
+		nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 20, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -10)}}
+		customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+		customerStore := new(mocks.CustomerStore)
+		if tt.expectedReason != "" {
+			customerStore.On("SaveCustomer", customer).Return(nil)
+		}
+
+		err := UpdateAccountStatus(customerID, libraryService, customerStore, WithClock(func() time.Time { return now }), WithAccountStatusPolicy(tt.policy))
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expectedReason != "", customer.IsLocked)
+		assert.Equal(t, tt.expectedReason, customer.LockReason)
+
+		libraryService.AssertExpectations(t)
+		customerStore.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,68 @@
+package tldr
+
+import (
+	"fmt"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// Permission operation an actor may be allowed to perform
+type Permission string
+
+// Permissions checked by lending operations
+const (
+	PermissionLend           Permission = "lend"
+	PermissionRenew          Permission = "renew"
+	PermissionWaive          Permission = "waive"
+	PermissionExceedLimit    Permission = "exceed limit"
+	PermissionUnlockCustomer Permission = "unlock customer"
+	PermissionMigrate        Permission = "migrate"
+	PermissionTransfer       Permission = "transfer"
+	PermissionCollectPayment Permission = "collect payment"
+	PermissionRefund         Permission = "refund"
+	// Renew at the desk beyond the renewals of the tier and past holds of other customers
+	PermissionRenewBeyondLimit Permission = "renew beyond limit"
+)
+
+var rolePermissions = map[servicelib.Role][]Permission{
+	servicelib.RoleSelfService:   {PermissionLend, PermissionRenew},
+	servicelib.RoleLibrarian:     {PermissionLend, PermissionRenew, PermissionUnlockCustomer, PermissionTransfer, PermissionRenewBeyondLimit, PermissionCollectPayment},
+	servicelib.RoleSupervisor:    {PermissionLend, PermissionRenew, PermissionUnlockCustomer, PermissionWaive, PermissionExceedLimit, PermissionTransfer, PermissionRenewBeyondLimit, PermissionCollectPayment, PermissionRefund},
+	servicelib.RoleAdministrator: {PermissionLend, PermissionRenew, PermissionUnlockCustomer, PermissionWaive, PermissionExceedLimit, PermissionMigrate, PermissionTransfer, PermissionRenewBeyondLimit, PermissionCollectPayment, PermissionRefund},
+}
+
+// PermissionDeniedError actor is not permitted to perform the operation
+type PermissionDeniedError struct {
+	Actor      servicelib.Actor
+	Permission Permission
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("Permission denied, %s %s is not permitted to %s", e.Actor.Type, e.Actor.ID, e.Permission)
+}
+
+// HasPermission tells if any of the roles of actor gives the permission
+func HasPermission(actor servicelib.Actor, permission Permission) bool {
+	for _, role := range actor.Roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func authorize(actor servicelib.Actor, permission Permission) error {
+	if !HasPermission(actor, permission) {
+		return &PermissionDeniedError{Actor: actor, Permission: permission}
+	}
+	return nil
+}
+
+func authorizeLendOrRenew(tx *transaction, isRenewal bool) error {
+	if isRenewal {
+		return authorize(tx.actor, PermissionRenew)
+	}
+	return authorize(tx.actor, PermissionLend)
+}
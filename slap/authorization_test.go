@@ -0,0 +1,115 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPermission(t *testing.T) {
+	testCases := []struct {
+		actor      servicelib.Actor
+		permission Permission
+		expected   bool
+	}{
+		{kiosk, PermissionLend, true},
+		{kiosk, PermissionRenew, true},
+		{kiosk, PermissionWaive, false},
+		{kiosk, PermissionUnlockCustomer, false},
+		{librarian, PermissionUnlockCustomer, true},
+		{librarian, PermissionWaive, false},
+		{supervisor, PermissionWaive, true},
+		{supervisor, PermissionExceedLimit, true},
+		{supervisor, PermissionMigrate, false},
+		{administrator, PermissionMigrate, true},
+		{librarian, PermissionTransfer, true},
+		{kiosk, PermissionTransfer, false},
+		{servicelib.Actor{Type: servicelib.ActorTypePortal, ID: "portal"}, PermissionLend, false},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, HasPermission(tt.actor, tt.permission))
+	}
+}
+
+func TestLendPermissionDenied(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	portal := servicelib.Actor{Type: servicelib.ActorTypePortal, ID: "portal"}
+
+	testCases := []struct {
+		book        *servicelib.Book
+		expectedErr string
+	}{
+		{&servicelib.Book{ID: bookID}, "Permission denied, portal portal is not permitted to lend"},
+		{&servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID}}, "Permission denied, portal portal is not permitted to renew"},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(tt.book)
+
+		err := LendBook(portal, branch, bookID, customerID, libraryService)
+		assert.Error(t, err)
+		assert.IsType(t, &PermissionDeniedError{}, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestKioskCannotOverride(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	override := Override{ApprovedBy: &kiosk, Reason: servicelib.OverrideReasonHardship, ExceedLimit: true}
+
+	err := LendBook(kiosk, branch, bookID, customerID, libraryService, WithOverride(override), WithAuditLog(new(mocks.AuditLog)))
+	assert.Error(t, err)
+	assert.Equal(t, &PermissionDeniedError{Actor: kiosk, Permission: PermissionExceedLimit}, err)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestUnlockCustomer(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	customer := &servicelib.Customer{ID: customerID, IsLocked: true, LockReason: "lost library card", LockedAt: now.AddDate(0, -1, 0)}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionUnlockCustomer, Time: now, CustomerID: customerID, BookIDs: []string{}, PerformedBy: librarian.ID}).Return(nil)
+
+	err := UnlockCustomer(librarian, customerID, libraryService, customerStore, WithClock(func() time.Time { return now }), WithAuditLog(auditLog))
+	assert.Nil(t, err)
+	assert.False(t, customer.IsLocked)
+	assert.Equal(t, servicelib.LockReason(""), customer.LockReason)
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestUnlockCustomerPermissionDenied(t *testing.T) {
+	libraryService := new(mocks.LibraryService)
+	customerStore := new(mocks.CustomerStore)
+
+	err := UnlockCustomer(kiosk, 123456, libraryService, customerStore)
+	assert.Error(t, err)
+	assert.Equal(t, "Permission denied, kiosk kiosk-1 is not permitted to unlock customer", err.Error())
+
+	err = MigrateCustomerBirthDates(supervisor, []int{123456}, libraryService, customerStore)
+	assert.Error(t, err)
+	assert.Equal(t, "Permission denied, staff kari is not permitted to migrate", err.Error())
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
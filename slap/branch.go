@@ -0,0 +1,100 @@
+package tldr
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// Calendar days a branch is open to receive returns
+type Calendar struct {
+	ClosedWeekdays []time.Weekday
+	// Holidays and other days closed, only the date is used
+	ClosedDates []time.Time
+}
+
+// IsOpen whether the branch is open on the date of t
+func (c *Calendar) IsOpen(t time.Time) bool {
+	for _, weekday := range c.ClosedWeekdays {
+		if t.Weekday() == weekday {
+			return false
+		}
+	}
+	for _, date := range c.ClosedDates {
+		if isSameDate(date, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// NextOpenDay t moved forward by whole days until the branch is open
+func (c *Calendar) NextOpenDay(t time.Time) time.Time {
+	// A calendar closed every day would otherwise never end
+	for i := 0; i < 366 && !c.IsOpen(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func isSameDate(a time.Time, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// BranchPolicy lending rules of a branch
+type BranchPolicy struct {
+	// Loan period in days replacing that of the tier, 0 to keep it
+	LoanDays int
+	// Books lended here are returned to the branch owning them instead of here
+	ReturnToHomeBranch bool
+	// Return dates on closed days are moved to the next open day
+	Calendar Calendar
+}
+
+// BranchConfig lending rules by branch
+type BranchConfig struct {
+	// Branches not listed lend with the default policy
+	Branches map[string]BranchPolicy
+}
+
+// PolicyFor lending rules of branch
+func (c *BranchConfig) PolicyFor(branch string) BranchPolicy {
+	return c.Branches[branch]
+}
+
+// WithBranches sets the lending rules of each branch
+func WithBranches(config *BranchConfig) LendOption {
+	return func(tx *transaction) {
+		tx.branches = config
+	}
+}
+
+func atBranch(branch string) LendOption {
+	return func(tx *transaction) {
+		tx.branch = branch
+	}
+}
+
+// GetLocation branch book was last seen at
+func GetLocation(book *servicelib.Book) string {
+	if book.Location != "" {
+		return book.Location
+	}
+	return book.HomeBranch
+}
+
+// Book in hand at the branch of the transaction
+func recordLocation(tx *transaction, book *servicelib.Book) {
+	if tx.branch != "" {
+		book.Location = tx.branch
+	}
+}
+
+func getDueBackAt(tx *transaction, book *servicelib.Book) string {
+	if tx.branches.PolicyFor(tx.branch).ReturnToHomeBranch && book.HomeBranch != "" {
+		return book.HomeBranch
+	}
+	return tx.branch
+}
@@ -0,0 +1,90 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarNextOpenDay(t *testing.T) {
+	// Monday
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	calendar := &Calendar{
+		ClosedWeekdays: []time.Weekday{time.Saturday, time.Sunday},
+		ClosedDates:    []time.Time{time.Date(2019, time.September, 16, 0, 0, 0, 0, time.UTC)},
+	}
+
+	assert.Equal(t, now, calendar.NextOpenDay(now))
+	assert.Equal(t, now.AddDate(0, 0, 8), calendar.NextOpenDay(now.AddDate(0, 0, 5)))
+	assert.Equal(t, now, (&Calendar{}).NextOpenDay(now))
+
+	closed := &Calendar{ClosedWeekdays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}}
+	assert.False(t, closed.IsOpen(closed.NextOpenDay(now)))
+}
+
+func TestLendBookAtBranch(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	config := &BranchConfig{Branches: map[string]BranchPolicy{
+		"bokbuss": {LoanDays: 21, ReturnToHomeBranch: true},
+		"nord":    {Calendar: Calendar{ClosedWeekdays: []time.Weekday{time.Monday}}},
+	}}
+
+	testCases := []struct {
+		branch           string
+		dueBackAt        string
+		latestReturnDate time.Time
+	}{
+		{"sentrum", "sentrum", now.AddDate(0, 0, 7)},
+		// Loan period of the mobile library, returned on the next day nord is open
+		{"bokbuss", "nord", now.AddDate(0, 0, 22)},
+		{"nord", "nord", now.AddDate(0, 0, 8)},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, HomeBranch: "nord"}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+		libraryService.On("SaveBook", book).Return(nil)
+
+		err := LendBook(librarian, tt.branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithBranches(config))
+		assert.Nil(t, err)
+		assert.Equal(t, tt.branch, book.CurrentLend.LentAt)
+		assert.Equal(t, tt.dueBackAt, book.CurrentLend.DueBackAt)
+		assert.Equal(t, tt.latestReturnDate, book.CurrentLend.LatestReturnDate, tt.branch)
+		assert.Equal(t, tt.branch, GetLocation(book))
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestRenewLendUsesBranchDueBackAt(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	config := &BranchConfig{Branches: map[string]BranchPolicy{"bokbuss": {LoanDays: 21}}}
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord", Location: "bokbuss", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), LentAt: "bokbuss", DueBackAt: "bokbuss"}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithBranches(config))
+	assert.Nil(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 21), book.CurrentLend.LatestReturnDate)
+	// Renewed without the book in hand
+	assert.Equal(t, "bokbuss", book.Location)
+	libraryService.AssertExpectations(t)
+}
+
+func TestGetLocation(t *testing.T) {
+	assert.Equal(t, "nord", GetLocation(&servicelib.Book{HomeBranch: "nord"}))
+	assert.Equal(t, "sentrum", GetLocation(&servicelib.Book{HomeBranch: "nord", Location: "sentrum"}))
+	assert.Equal(t, "", GetLocation(&servicelib.Book{}))
+}
@@ -0,0 +1,84 @@
+package tldr
+
+import (
+	"strings"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/notification"
+	"github.com/eirikbell/slap/servicelib"
+)
+
+func recordCirculation(tx *transaction, action servicelib.AuditAction, customer *servicelib.Customer, books []*servicelib.Book, amount int) {
+	recordCirculationEntry(tx, &servicelib.AuditEntry{
+		Action:      action,
+		Time:        tx.now,
+		CustomerID:  customer.ID,
+		BookIDs:     getBookIDs(books),
+		Amount:      amount,
+		PerformedBy: tx.actor.ID,
+		Tier:        getTier(customer),
+		DaysLate:    sumDaysLate(books, tx),
+		Branch:      tx.branch,
+	})
+}
+
+// Circulation is recorded for statistics only, a failing audit log does not undo the transaction
+func recordCirculationEntry(tx *transaction, entry *servicelib.AuditEntry) {
+	if tx.auditLog == nil {
+		return
+	}
+
+	_ = tx.auditLog.RecordAudit(entry)
+}
+
+func sumDaysLate(books []*servicelib.Book, tx *transaction) int {
+	days := 0
+	for _, book := range books {
+		if book.CurrentLend != nil && book.CurrentLend.LatestReturnDate.Before(tx.now) {
+			days += calculateDaysLate(book, tx.now)
+		}
+	}
+	return days
+}
+
+// A failing publisher does not undo the transaction, events not stored in the outbox must be registered manually
+func publishEvent(tx *transaction, event events.Event) {
+	if tx.publisher == nil {
+		return
+	}
+
+	if err := tx.publisher.Publish(event); err != nil {
+		customerID, bookIDs := getEventIDs(event)
+		logDecision(tx, logging.LevelError, "Publishing event failed", logging.String("eventType", string(event.EventType())),
+			logging.CustomerID(customerID), logging.String("bookIds", strings.Join(bookIDs, ",")), logging.Error(err))
+	}
+}
+
+func getEventIDs(event events.Event) (int, []string) {
+	switch e := event.(type) {
+	case *events.BookLent:
+		return e.CustomerID, []string{e.BookID}
+	case *events.LendRenewed:
+		return e.CustomerID, []string{e.BookID}
+	case *events.FeeCollected:
+		return e.CustomerID, e.BookIDs
+	case *events.LendRejected:
+		return e.CustomerID, []string{e.BookID}
+	case *events.BookReturned:
+		return e.CustomerID, []string{e.BookID}
+	}
+	return 0, []string{}
+}
+
+// Partners see the event, the customer facing message is used as internal messages may name other customers
+func publishLendRejected(tx *transaction, bookID string, customerID int, err error) {
+	publishEvent(tx, &events.LendRejected{
+		Time:        tx.now,
+		CustomerID:  customerID,
+		BookID:      bookID,
+		Code:        string(findLendingError(err).Code),
+		Reason:      Localize(err, notification.DefaultLanguage),
+		PerformedBy: tx.actor.ID,
+	})
+}
@@ -0,0 +1,195 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCirculationRecordedOnLendAndCollectedFee(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20, Tier: servicelib.TierStudent}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 15).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionCollectFee, Time: now, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 15, PerformedBy: librarian.ID, Tier: servicelib.TierStudent, DaysLate: 2, Branch: branch}).Return(nil)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: now, CustomerID: customerID, BookIDs: []string{bookID}, PerformedBy: librarian.ID, Tier: servicelib.TierStudent, Branch: branch}).Return(fmt.Errorf("DB error"))
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithAuditLog(auditLog))
+	assert.Nil(t, err)
+
+	libraryService.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestCirculationRecordedOnRenewal(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionRenew, Time: now, CustomerID: customerID, BookIDs: []string{bookID}, PerformedBy: kiosk.ID, Tier: servicelib.TierAdult}).Return(nil)
+
+	err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithAuditLog(auditLog))
+	assert.Nil(t, err)
+
+	libraryService.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestCirculationRecordedOnBlockedPayment(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -3)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 10, Tier: servicelib.TierChild}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionPaymentBlocked, Time: now, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 15, PerformedBy: librarian.ID, Tier: servicelib.TierChild, DaysLate: 3, Branch: branch}).Return(nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithAuditLog(auditLog))
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot collect payment for 1 books, customer is younger than 13", err.Error())
+
+	libraryService.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestEventsPublishedOnLend(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	bus := events.NewInProcessBus()
+	published := []events.Event{}
+	record := func(event events.Event) error {
+		published = append(published, event)
+		return fmt.Errorf("Accounting down")
+	}
+	bus.Subscribe(events.TypeFeeCollected, record)
+	bus.Subscribe(events.TypeBookLent, record)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithEvents(bus))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []events.Event{
+		&events.FeeCollected{Time: now, CustomerID: customerID, PayerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 20},
+		&events.BookLent{Time: now, CustomerID: customerID, BookID: bookID, LatestReturnDate: now.AddDate(0, 0, 7), PerformedBy: librarian.ID},
+	}, published)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestEventPublishedOnRejectedLend(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(nil)
+	libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{})
+	bus := events.NewInProcessBus()
+	published := []events.Event{}
+	bus.Subscribe(events.TypeLendRejected, func(event events.Event) error {
+		published = append(published, event)
+		return nil
+	})
+
+	err := LendBook(kiosk, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithEvents(bus))
+	assert.Error(t, err)
+
+	assert.Equal(t, []events.Event{
+		&events.LendRejected{Time: now, CustomerID: customerID, BookID: bookID, Code: string(CodeBookNotFound), Reason: "The book was not found", PerformedBy: kiosk.ID},
+	}, published)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendRejectedEventHidesOtherCustomer(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: 654321}})
+	bus := events.NewInProcessBus()
+	published := []events.Event{}
+	bus.Subscribe(events.TypeLendRejected, func(event events.Event) error {
+		published = append(published, event)
+		return nil
+	})
+
+	err := LendBook(kiosk, branch, bookID, customerID, libraryService, WithEvents(bus))
+	assert.Equal(t, "Book is currently lended to customer 654321", err.Error())
+	assert.Len(t, published, 1)
+	assert.Equal(t, "The book is already lent to someone else", published[0].(*events.LendRejected).Reason)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestEventNotPublishedLogged(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	bus := events.NewInProcessBus()
+	bus.Subscribe(events.TypeBookLent, func(events.Event) error {
+		return fmt.Errorf("Outbox down")
+	})
+	logger := &logging.RecordingLogger{}
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithEvents(bus), WithLogger(logger))
+	assert.Nil(t, err)
+
+	entry := logger.Find("Publishing event failed")
+	assert.Equal(t, logging.LevelError, entry.Level)
+	assert.Equal(t, string(events.TypeBookLent), entry.Field("eventType"))
+	assert.Equal(t, customerID, entry.Field("customerId"))
+	assert.Equal(t, bookID, entry.Field("bookIds"))
+	libraryService.AssertExpectations(t)
+}
@@ -0,0 +1,50 @@
+package tldr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// MigrateCustomerBirthDates registers an estimated birth date on customers only having a stored age
+func MigrateCustomerBirthDates(actor servicelib.Actor, customerIDs []int, libraryService servicelib.LibraryService, customerStore servicelib.CustomerStore, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionMigrate); err != nil {
+		return err
+	}
+
+	fail := []string{}
+	for _, customerID := range customerIDs {
+		// Must manually register later
+		if err := migrateCustomerBirthDate(tx, customerID, libraryService, customerStore); err != nil {
+			fail = append(fail, strconv.Itoa(customerID))
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Migrating birth date failed, manually register birth date for customers %s", strings.Join(fail, ", "))
+	}
+	return nil
+}
+
+func migrateCustomerBirthDate(tx *transaction, customerID int, libraryService servicelib.LibraryService, customerStore servicelib.CustomerStore) error {
+	customer, err := libraryService.GetCustomer(customerID)
+	if err != nil {
+		return errors.Wrap(err, "Customer not found")
+	}
+
+	if !customer.BirthDate.IsZero() {
+		return nil
+	}
+
+	customer.BirthDate = estimateBirthDate(customer.Age, tx.now)
+	return customerStore.SaveCustomer(customer)
+}
+
+func estimateBirthDate(age int, now time.Time) time.Time {
+	// Halfway between the birthdays giving the stored age, keeps the age unchanged for the next six months
+	return now.AddDate(-age, -6, 0)
+}
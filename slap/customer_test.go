@@ -0,0 +1,77 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateCustomerBirthDates(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	birthDate := time.Date(2001, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	ageOnly := &servicelib.Customer{ID: 1, Age: 20}
+	withBirthDate := &servicelib.Customer{ID: 2, Age: 3, BirthDate: birthDate}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", ageOnly.ID).Return(ageOnly, nil)
+	libraryService.On("GetCustomer", withBirthDate.ID).Return(withBirthDate, nil)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", ageOnly).Return(nil)
+
+	err := MigrateCustomerBirthDates(administrator, []int{ageOnly.ID, withBirthDate.ID}, libraryService, customerStore, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 20, ageOnly.AgeAt(now))
+	assert.Equal(t, 20, ageOnly.AgeAt(now.AddDate(0, 5, 0)))
+	assert.Equal(t, 21, ageOnly.AgeAt(now.AddDate(0, 7, 0)))
+	assert.Equal(t, birthDate, withBirthDate.BirthDate)
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestMigrateCustomerBirthDatesFails(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	expectedErr := fmt.Errorf("DB error")
+
+	customer1 := &servicelib.Customer{ID: 1, Age: 20}
+	customer2 := &servicelib.Customer{ID: 2, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customer1.ID).Return(customer1, nil)
+	libraryService.On("GetCustomer", customer2.ID).Return(customer2, nil)
+	libraryService.On("GetCustomer", 3).Return(nil, expectedErr)
+	customerStore := new(mocks.CustomerStore)
+	customerStore.On("SaveCustomer", customer1).Return(nil)
+	customerStore.On("SaveCustomer", customer2).Return(expectedErr)
+
+	err := MigrateCustomerBirthDates(administrator, []int{1, 2, 3}, libraryService, customerStore, WithClock(func() time.Time { return now }))
+	assert.Error(t, err)
+	assert.Equal(t, "Migrating birth date failed, manually register birth date for customers 2, 3", err.Error())
+
+	libraryService.AssertExpectations(t)
+	customerStore.AssertExpectations(t)
+}
+
+func TestCustomerAgeAt(t *testing.T) {
+	customer := &servicelib.Customer{BirthDate: time.Date(2006, time.September, 10, 0, 0, 0, 0, time.UTC)}
+
+	testCases := []struct {
+		at          time.Time
+		expectedAge int
+	}{
+		{time.Date(2006, time.September, 10, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2019, time.September, 9, 23, 0, 0, 0, time.UTC), 12},
+		{time.Date(2019, time.September, 10, 0, 0, 0, 0, time.UTC), 13},
+		{time.Date(2019, time.December, 1, 0, 0, 0, 0, time.UTC), 13},
+		{time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), 13},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expectedAge, customer.AgeAt(tt.at))
+	}
+}
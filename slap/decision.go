@@ -0,0 +1,53 @@
+package tldr
+
+// RuleName lending rule recorded in a decision trace
+type RuleName string
+
+// Rules evaluated when lending or renewing
+const (
+	RuleBookIDValid      RuleName = "book-id-valid"
+	RuleBookAvailable    RuleName = "book-available"
+	RuleNotInTransit     RuleName = "not-in-transit"
+	RuleMaterialLendable RuleName = "material-lendable"
+	RuleAccountNotLocked RuleName = "account-not-locked"
+	RuleLendingLimit     RuleName = "lending-limit"
+	RuleUnderagePayment  RuleName = "underage-payment"
+	RuleFeeTotal         RuleName = "fee-total"
+)
+
+// RuleEvaluation outcome of a single rule and the values it was evaluated with
+type RuleEvaluation struct {
+	Rule   RuleName               `json:"rule"`
+	Passed bool                   `json:"passed"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// DecisionTrace every rule evaluated in a transaction, in the order they were evaluated
+type DecisionTrace struct {
+	Evaluations []*RuleEvaluation `json:"evaluations"`
+}
+
+// FirstFailure rule the transaction was rejected on, nil if every rule passed
+func (d *DecisionTrace) FirstFailure() *RuleEvaluation {
+	for _, evaluation := range d.Evaluations {
+		if !evaluation.Passed {
+			return evaluation
+		}
+	}
+	return nil
+}
+
+// WithDecisionTrace records every rule evaluated in the transaction in trace
+func WithDecisionTrace(trace *DecisionTrace) LendOption {
+	return func(tx *transaction) {
+		tx.decisionTrace = trace
+	}
+}
+
+func traceRule(tx *transaction, rule RuleName, passed bool, values map[string]interface{}) {
+	if tx.decisionTrace == nil {
+		return
+	}
+
+	tx.decisionTrace.Evaluations = append(tx.decisionTrace.Evaluations, &RuleEvaluation{Rule: rule, Passed: passed, Values: values})
+}
@@ -0,0 +1,107 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionTraceOfLend(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	trace := &DecisionTrace{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithDecisionTrace(trace))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []*RuleEvaluation{
+		{Rule: RuleBookIDValid, Passed: true, Values: map[string]interface{}{"bookId": bookID, "length": 5, "minLength": 5}},
+		{Rule: RuleBookAvailable, Passed: true, Values: map[string]interface{}{"found": true, "lentToCustomerId": 0}},
+		{Rule: RuleNotInTransit, Passed: true, Values: map[string]interface{}{"inTransitTo": ""}},
+		{Rule: RuleMaterialLendable, Passed: true, Values: map[string]interface{}{"materialType": "book"}},
+		{Rule: RuleAccountNotLocked, Passed: true, Values: map[string]interface{}{"locked": false, "lockReason": ""}},
+		{Rule: RuleAgeRestriction, Passed: true, Values: map[string]interface{}{"age": 20, "ageRating": 0, "guardianConsent": false}},
+		{Rule: RuleLendingLimit, Passed: true, Values: map[string]interface{}{"lends": 1, "limit": 3, "renewal": false}},
+		{Rule: RuleUnderagePayment, Passed: true, Values: map[string]interface{}{"age": 20, "minimumAge": 13, "guardianPays": false}},
+		{Rule: RuleFeeTotal, Passed: true, Values: map[string]interface{}{"amount": 20, "books": 1, "payerId": customerID}},
+	}, trace.Evaluations)
+	assert.Nil(t, trace.FirstFailure())
+	libraryService.AssertExpectations(t)
+}
+
+func TestDecisionTraceOfRejectedLend(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -3)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 10}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+
+	trace := &DecisionTrace{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithDecisionTrace(trace))
+	assert.Error(t, err)
+
+	assert.Len(t, trace.Evaluations, 8)
+	assert.Equal(t, &RuleEvaluation{Rule: RuleUnderagePayment, Passed: false, Values: map[string]interface{}{"age": 10, "minimumAge": 13, "guardianPays": false}}, trace.FirstFailure())
+}
+
+func TestDecisionTraceStopsAtFailedRule(t *testing.T) {
+	customerID := 123456
+
+	testCases := []struct {
+		bookID       string
+		book         *servicelib.Book
+		expectedRule RuleName
+		evaluations  int
+	}{
+		{"1234", nil, RuleBookIDValid, 1},
+		{"12345", nil, RuleBookAvailable, 2},
+		{"12345", &servicelib.Book{ID: "12345", CurrentLend: &servicelib.Lend{CustomerID: 654321}}, RuleBookAvailable, 2},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", tt.bookID).Return(tt.book)
+		libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{})
+
+		trace := &DecisionTrace{}
+		err := LendBook(librarian, branch, tt.bookID, customerID, libraryService, WithDecisionTrace(trace))
+		assert.Error(t, err)
+		assert.Len(t, trace.Evaluations, tt.evaluations)
+		assert.Equal(t, tt.expectedRule, trace.FirstFailure().Rule)
+	}
+}
+
+func TestDecisionTraceOfLockedAccount(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, IsLocked: true, LockReason: "lost card"}, nil)
+
+	trace := &DecisionTrace{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithDecisionTrace(trace))
+	assert.Error(t, err)
+	assert.Equal(t, &RuleEvaluation{Rule: RuleAccountNotLocked, Passed: false, Values: map[string]interface{}{"locked": true, "lockReason": "lost card"}}, trace.FirstFailure())
+}
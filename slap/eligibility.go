@@ -0,0 +1,227 @@
+package tldr
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// Rules only evaluated by the eligibility rule engine
+const (
+	RuleOverdueBlock   RuleName = "overdue-block"
+	RuleAgeRestriction RuleName = "age-restriction"
+)
+
+// EligibilityContext facts eligibility rules are evaluated on
+type EligibilityContext struct {
+	Customer *servicelib.Customer
+	// Book to lend or renew, nil when renewing lends without the book in hand
+	Book *servicelib.Book
+	// Books currently lended to customer
+	BookLends []*servicelib.Book
+	IsRenewal bool
+	Now       time.Time
+	Policy    TierPolicy
+}
+
+// Rule named eligibility check, Check returns a lending error when customer is not eligible
+type Rule struct {
+	Name  RuleName
+	Check func(*EligibilityContext) error
+	// Values the rule is evaluated with, shown in the decision trace
+	Values func(*EligibilityContext) map[string]interface{}
+}
+
+// AllOf rule passing when every rule passes, failing with the first failure
+func AllOf(name RuleName, rules ...Rule) Rule {
+	return Rule{
+		Name: name,
+		Check: func(ctx *EligibilityContext) error {
+			for _, rule := range rules {
+				if err := rule.Check(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Values: combineValues(rules),
+	}
+}
+
+// AnyOf rule passing when a single rule passes, failing with the last failure
+func AnyOf(name RuleName, rules ...Rule) Rule {
+	return Rule{
+		Name: name,
+		Check: func(ctx *EligibilityContext) error {
+			var err error
+			for _, rule := range rules {
+				if err = rule.Check(ctx); err == nil {
+					return nil
+				}
+			}
+			return err
+		},
+		Values: combineValues(rules),
+	}
+}
+
+// Values of each rule are kept apart by the rule name
+func combineValues(rules []Rule) func(*EligibilityContext) map[string]interface{} {
+	return func(ctx *EligibilityContext) map[string]interface{} {
+		values := map[string]interface{}{}
+		for _, rule := range rules {
+			values[string(rule.Name)] = getRuleValues(rule, ctx)
+		}
+		return values
+	}
+}
+
+func getRuleValues(rule Rule, ctx *EligibilityContext) map[string]interface{} {
+	if rule.Values == nil {
+		return map[string]interface{}{}
+	}
+	return rule.Values(ctx)
+}
+
+// AccountNotLockedRule rejects customers whose account is locked
+var AccountNotLockedRule = Rule{
+	Name: RuleAccountNotLocked,
+	Check: func(ctx *EligibilityContext) error {
+		if ctx.Customer.IsLocked {
+			return createAccountLockedError(ctx.Customer)
+		}
+		return nil
+	},
+	Values: func(ctx *EligibilityContext) map[string]interface{} {
+		return map[string]interface{}{"locked": ctx.Customer.IsLocked, "lockReason": string(ctx.Customer.LockReason)}
+	},
+}
+
+// LendingLimitRule rejects customers with as many books as the tier allows, renewals may bring the count down
+var LendingLimitRule = Rule{
+	Name: RuleLendingLimit,
+	Check: func(ctx *EligibilityContext) error {
+		return validateLendingLimitNotExceeded(ctx.BookLends, ctx.IsRenewal, ctx.Policy)
+	},
+	Values: func(ctx *EligibilityContext) map[string]interface{} {
+		return map[string]interface{}{"lends": len(ctx.BookLends), "limit": ctx.Policy.MaxLends, "renewal": ctx.IsRenewal}
+	},
+}
+
+// OverdueBlockRule rejects new lends until overdue books are returned, renewals are still allowed
+var OverdueBlockRule = Rule{
+	Name: RuleOverdueBlock,
+	Check: func(ctx *EligibilityContext) error {
+		overdue := filterNotReturnedBookLends(ctx.BookLends, ctx.Now)
+		if !ctx.IsRenewal && len(overdue) > 0 {
+			return lendingErrorf(CodeOverdueBlock, len(overdue), "Customer has %d overdue books", len(overdue))
+		}
+		return nil
+	},
+	Values: func(ctx *EligibilityContext) map[string]interface{} {
+		return map[string]interface{}{"overdue": len(filterNotReturnedBookLends(ctx.BookLends, ctx.Now)), "renewal": ctx.IsRenewal}
+	},
+}
+
+// AgeRestrictionRule rejects lending material rated above the age of customer, unless a guardian has consented
+var AgeRestrictionRule = Rule{
+	Name: RuleAgeRestriction,
+	Check: func(ctx *EligibilityContext) error {
+		if ctx.Book == nil || isOldEnoughFor(ctx.Customer, ctx.Book, ctx.Now) || isAgeRestrictionWaivedByGuardian(ctx.Customer, ctx.Now) {
+			return nil
+		}
+		return lendingErrorf(CodeAgeRestricted, 0, "Book %s is restricted to customers aged %d or older", ctx.Book.ID, ctx.Book.AgeRating)
+	},
+	Values: func(ctx *EligibilityContext) map[string]interface{} {
+		ageRating := 0
+		if ctx.Book != nil {
+			ageRating = ctx.Book.AgeRating
+		}
+		return map[string]interface{}{"age": ctx.Customer.AgeAt(ctx.Now), "ageRating": ageRating, "guardianConsent": isAgeRestrictionWaivedByGuardian(ctx.Customer, ctx.Now)}
+	},
+}
+
+// Same age as the payment rules, at the time of the transaction
+func isOldEnoughFor(customer *servicelib.Customer, book *servicelib.Book, now time.Time) bool {
+	return customer.AgeAt(now) >= book.AgeRating
+}
+
+func isAgeRestrictionWaivedByGuardian(customer *servicelib.Customer, now time.Time) bool {
+	return customer.AgeAt(now) < 18 && customer.GuardianID != 0 && customer.GuardianConsent.AgeRestrictedMaterial
+}
+
+// EligibilityRules every known rule in the order they are evaluated
+var EligibilityRules = []Rule{MaterialLendableRule, AccountNotLockedRule, AgeRestrictionRule, OverdueBlockRule, LendingLimitRule}
+
+// DefaultEnabledRules rules enabled at branches without a configuration of their own
+var DefaultEnabledRules = []RuleName{RuleMaterialLendable, RuleAccountNotLocked, RuleAgeRestriction, RuleLendingLimit}
+
+// EligibilityConfig eligibility rules enabled by branch
+type EligibilityConfig struct {
+	// Branches not listed get DefaultEnabledRules
+	Branches map[string][]RuleName
+}
+
+// RulesFor rules enabled at branch, in evaluation order
+func (c *EligibilityConfig) RulesFor(branch string) []Rule {
+	enabled, ok := c.Branches[branch]
+	if !ok {
+		enabled = DefaultEnabledRules
+	}
+
+	rules := []Rule{}
+	for _, rule := range EligibilityRules {
+		if containsRuleName(enabled, rule.Name) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func containsRuleName(names []RuleName, name RuleName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WithEligibilityConfig sets the rules enabled at each branch, the branch of the transaction decides which apply
+func WithEligibilityConfig(config *EligibilityConfig) LendOption {
+	return func(tx *transaction) {
+		tx.eligibilityConfig = config
+	}
+}
+
+// WithEligibilityRules replaces the rules deciding whether customer may lend, evaluated in the order given
+func WithEligibilityRules(rules ...Rule) LendOption {
+	return func(tx *transaction) {
+		tx.eligibilityRules = rules
+	}
+}
+
+func validateEligibility(tx *transaction, ctx *EligibilityContext) error {
+	for _, rule := range tx.eligibilityRules {
+		err := rule.Check(ctx)
+		logDecision(tx, logging.LevelDebug, "Eligibility rule evaluated", logging.CustomerID(ctx.Customer.ID), logging.String("rule", string(rule.Name)), logging.Bool("passed", err == nil))
+		traceRule(tx, rule.Name, err == nil, getRuleValues(rule, ctx))
+		if err == nil {
+			continue
+		}
+
+		if err := overrideRule(tx, ctx.Customer, rule, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Only the lending limit can be exceeded by staff
+func overrideRule(tx *transaction, customer *servicelib.Customer, rule Rule, ruleErr error) error {
+	if rule.Name != RuleLendingLimit {
+		return ruleErr
+	}
+	return overrideLendingLimit(tx, customer, ruleErr)
+}
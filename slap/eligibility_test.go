@@ -0,0 +1,196 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEligibilityContext(customer *servicelib.Customer, bookLends []*servicelib.Book, isRenewal bool, now time.Time) *EligibilityContext {
+	return &EligibilityContext{Customer: customer, BookLends: bookLends, IsRenewal: isRenewal, Now: now, Policy: GetTierPolicy(customer)}
+}
+
+func TestAccountNotLockedRule(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, AccountNotLockedRule.Check(newEligibilityContext(&servicelib.Customer{}, nil, false, now)))
+
+	err := AccountNotLockedRule.Check(newEligibilityContext(&servicelib.Customer{IsLocked: true, LockReason: servicelib.LockReasonOverdue}, nil, false, now))
+	assert.Equal(t, CodeAccountLockedOverdue, findLendingError(err).Code)
+}
+
+func TestLendingLimitRule(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	customer := &servicelib.Customer{Tier: servicelib.TierAdult}
+	threeBooks := []*servicelib.Book{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+
+	assert.Nil(t, LendingLimitRule.Check(newEligibilityContext(customer, threeBooks[:2], false, now)))
+	assert.Equal(t, CodeLendLimitReached, findLendingError(LendingLimitRule.Check(newEligibilityContext(customer, threeBooks, false, now))).Code)
+	assert.Nil(t, LendingLimitRule.Check(newEligibilityContext(customer, threeBooks, true, now)))
+	assert.Equal(t, map[string]interface{}{"lends": 3, "limit": 3, "renewal": true}, LendingLimitRule.Values(newEligibilityContext(customer, threeBooks, true, now)))
+}
+
+func TestOverdueBlockRule(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	customer := &servicelib.Customer{}
+	overdue := []*servicelib.Book{
+		{ID: "1", CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, -1)}},
+		{ID: "2", CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, 1)}},
+	}
+
+	err := OverdueBlockRule.Check(newEligibilityContext(customer, overdue, false, now))
+	assert.Equal(t, CodeOverdueBlock, findLendingError(err).Code)
+	assert.Equal(t, "Please return your overdue book before borrowing more", Localize(err, "en"))
+	assert.Nil(t, OverdueBlockRule.Check(newEligibilityContext(customer, overdue, true, now)))
+	assert.Nil(t, OverdueBlockRule.Check(newEligibilityContext(customer, overdue[1:], false, now)))
+}
+
+func TestComposedRules(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	pass := Rule{Name: "pass", Check: func(*EligibilityContext) error { return nil }}
+	fail := func(name RuleName) Rule {
+		return Rule{Name: name, Check: func(*EligibilityContext) error { return fmt.Errorf("%s failed", name) }}
+	}
+	ctx := newEligibilityContext(&servicelib.Customer{}, nil, false, now)
+
+	assert.Nil(t, AllOf("all", pass, pass).Check(ctx))
+	assert.Equal(t, "first failed", AllOf("all", pass, fail("first"), fail("second")).Check(ctx).Error())
+	assert.Nil(t, AnyOf("any", fail("first"), pass).Check(ctx))
+	assert.Equal(t, "second failed", AnyOf("any", fail("first"), fail("second")).Check(ctx).Error())
+
+	composed := AllOf("composed", AccountNotLockedRule, pass)
+	assert.Equal(t, map[string]interface{}{
+		"account-not-locked": map[string]interface{}{"locked": false, "lockReason": ""},
+		"pass":               map[string]interface{}{},
+	}, composed.Values(ctx))
+}
+
+func TestEligibilityConfigRulesFor(t *testing.T) {
+	config := &EligibilityConfig{Branches: map[string][]RuleName{
+		"sentrum": {RuleLendingLimit, RuleOverdueBlock, RuleAccountNotLocked},
+		"bokbuss": {RuleAccountNotLocked},
+	}}
+
+	names := func(rules []Rule) []RuleName {
+		result := []RuleName{}
+		for _, rule := range rules {
+			result = append(result, rule.Name)
+		}
+		return result
+	}
+	// Evaluation order does not depend on the order in the configuration
+	assert.Equal(t, []RuleName{RuleAccountNotLocked, RuleOverdueBlock, RuleLendingLimit}, names(config.RulesFor("sentrum")))
+	assert.Equal(t, []RuleName{RuleAccountNotLocked}, names(config.RulesFor("bokbuss")))
+	assert.Equal(t, []RuleName{RuleMaterialLendable, RuleAccountNotLocked, RuleAgeRestriction, RuleLendingLimit}, names(config.RulesFor("nord")))
+}
+
+func TestLendBookWithBranchRules(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	config := &EligibilityConfig{Branches: map[string][]RuleName{"sentrum": {RuleAccountNotLocked, RuleOverdueBlock}}}
+
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+
+	err := LendBook(librarian, "sentrum", bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithEligibilityConfig(config))
+	assert.Error(t, err)
+	assert.Equal(t, CodeOverdueBlock, findLendingError(err).Code)
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookAtBranchWithoutRuleConfigured(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	config := &EligibilityConfig{Branches: map[string][]RuleName{"sentrum": {RuleAccountNotLocked, RuleOverdueBlock}}}
+
+	book := &servicelib.Book{ID: bookID}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	// Overdue books only block lending at sentrum, here they are paid for
+	err := LendBook(librarian, "nord", bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithEligibilityConfig(config))
+	assert.Nil(t, err)
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookWithoutLendingLimitRule(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{{ID: "1"}, {ID: "2"}, {ID: "3"}}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithEligibilityRules(AccountNotLockedRule))
+	assert.Nil(t, err)
+	libraryService.AssertExpectations(t)
+}
+
+func TestAgeRestrictionRule(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	book := &servicelib.Book{ID: "12345", AgeRating: 15}
+
+	testCases := []struct {
+		customer *servicelib.Customer
+		book     *servicelib.Book
+		eligible bool
+	}{
+		{&servicelib.Customer{Age: 15}, book, true},
+		{&servicelib.Customer{Age: 14}, book, false},
+		{&servicelib.Customer{Age: 14}, &servicelib.Book{ID: "12345"}, true},
+		{&servicelib.Customer{Age: 14}, nil, true},
+		// Turns 15 the day after the transaction
+		{&servicelib.Customer{Age: 30, BirthDate: time.Date(2004, time.September, 10, 0, 0, 0, 0, time.UTC)}, book, false},
+		{&servicelib.Customer{BirthDate: time.Date(2004, time.September, 9, 0, 0, 0, 0, time.UTC)}, book, true},
+		{&servicelib.Customer{Age: 12, GuardianID: 1, GuardianConsent: servicelib.GuardianConsent{AgeRestrictedMaterial: true}}, book, true},
+		{&servicelib.Customer{Age: 12, GuardianID: 1, GuardianConsent: servicelib.GuardianConsent{PayFines: true}}, book, false},
+		{&servicelib.Customer{Age: 12, GuardianConsent: servicelib.GuardianConsent{AgeRestrictedMaterial: true}}, book, false},
+	}
+
+	for i, tt := range testCases {
+		ctx := newEligibilityContext(tt.customer, nil, false, now)
+		ctx.Book = tt.book
+		err := AgeRestrictionRule.Check(ctx)
+		if tt.eligible {
+			assert.Nil(t, err, "case %d", i)
+		} else {
+			assert.Equal(t, CodeAgeRestricted, findLendingError(err).Code, "case %d", i)
+		}
+	}
+}
+
+func TestLendBookAgeRestricted(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID, AgeRating: 18})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 16}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, "Book 12345 is restricted to customers aged 18 or older", err.Error())
+	assert.Equal(t, "Bok 12345 har aldersgrense 18 år, en foresatt kan gi samtykke i skranken", Localize(err, "nb"))
+	libraryService.AssertExpectations(t)
+}
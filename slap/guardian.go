@@ -0,0 +1,50 @@
+package tldr
+
+import (
+	"fmt"
+
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// GetLendsForGuardedCustomer lets a guardian see the current lends of a minor linked to them
+func GetLendsForGuardedCustomer(guardianID int, customerID int, libraryService servicelib.LibraryService) ([]*servicelib.Book, error) {
+	customer, err := libraryService.GetCustomer(customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Customer not found")
+	}
+
+	if err := validateGuardianCanViewLends(guardianID, customer); err != nil {
+		return nil, err
+	}
+
+	bookLends, err := libraryService.GetLendsForCustomer(customer.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot retrieve current lends")
+	}
+	return bookLends, nil
+}
+
+func validateGuardianCanViewLends(guardianID int, customer *servicelib.Customer) error {
+	if customer.GuardianID == 0 || customer.GuardianID != guardianID {
+		return fmt.Errorf("Customer %d is not guardian of customer %d", guardianID, customer.ID)
+	}
+
+	if !customer.GuardianConsent.ViewLends {
+		return fmt.Errorf("Guardian %d has no consent to view lends of customer %d", guardianID, customer.ID)
+	}
+	return nil
+}
+
+func findGuardian(customer *servicelib.Customer, libraryService servicelib.LibraryService) (*servicelib.Customer, error) {
+	guardian, err := libraryService.GetCustomer(customer.GuardianID)
+	if err != nil {
+		return nil, wrapLendingError(err, CodeGuardianNotFound, "Guardian not found")
+	}
+
+	// Locked whether by staff or automatically, new fines are not routed to them
+	if guardian.IsLocked {
+		return nil, lendingErrorf(CodeGuardianLocked, 0, "Guardian %d account is locked", guardian.ID)
+	}
+	return guardian, nil
+}
@@ -0,0 +1,53 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardianViewsLends(t *testing.T) {
+	guardianID := 654321
+	customerID := 123456
+
+	customer := &servicelib.Customer{ID: customerID, Age: 9, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{ViewLends: true}}
+	lends := []*servicelib.Book{&servicelib.Book{ID: "12345"}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return(lends, nil)
+
+	result, err := GetLendsForGuardedCustomer(guardianID, customerID, libraryService)
+	assert.Nil(t, err)
+	assert.Equal(t, lends, result)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestGuardianCannotViewLends(t *testing.T) {
+	guardianID := 654321
+	customerID := 123456
+
+	testCases := []struct {
+		customer    *servicelib.Customer
+		expectedErr string
+	}{
+		{&servicelib.Customer{ID: customerID, Age: 9}, fmt.Sprintf("Customer %d is not guardian of customer %d", guardianID, customerID)},
+		{&servicelib.Customer{ID: customerID, Age: 9, GuardianID: 111111, GuardianConsent: servicelib.GuardianConsent{ViewLends: true}}, fmt.Sprintf("Customer %d is not guardian of customer %d", guardianID, customerID)},
+		{&servicelib.Customer{ID: customerID, Age: 9, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{PayFines: true}}, fmt.Sprintf("Guardian %d has no consent to view lends of customer %d", guardianID, customerID)},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetCustomer", customerID).Return(tt.customer, nil)
+
+		_, err := GetLendsForGuardedCustomer(guardianID, customerID, libraryService)
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,175 @@
+package tldr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// DebtPolicy how much a customer may owe and still lend books
+type DebtPolicy struct {
+	MaxBalance int
+}
+
+// DefaultDebtPolicy limit used unless configured for the transaction
+var DefaultDebtPolicy = DebtPolicy{
+	MaxBalance: 100,
+}
+
+// GetBalance amount customer owes the library, negative if customer has credit
+func GetBalance(customerID int, ledger servicelib.Ledger) (int, error) {
+	entries, err := ledger.GetLedgerEntries(customerID)
+	if err != nil {
+		return 0, errors.Wrap(err, "Cannot retrieve ledger")
+	}
+
+	return calculateBalance(entries), nil
+}
+
+// GetProjectedFees late fees customer would pay if the overdue books among bookLends were returned at now
+func GetProjectedFees(customer *servicelib.Customer, bookLends []*servicelib.Book, now time.Time) int {
+	return calculateTotalPriceForLateReturn(customer, filterNotReturnedBookLends(bookLends, now), now)
+}
+
+// GetDaysLate whole days, rounded up, book is overdue at now
+func GetDaysLate(book *servicelib.Book, now time.Time) int {
+	return calculateDaysLate(book, now)
+}
+
+// PayBalance collects payment for all or part of what customer owes
+func PayBalance(actor servicelib.Actor, customerID int, amount int, libraryService servicelib.LibraryService, ledger servicelib.Ledger, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionCollectPayment); err != nil {
+		return err
+	}
+
+	balance, err := GetBalance(customerID, ledger)
+	if err != nil {
+		return err
+	}
+
+	if amount <= 0 || amount > balance {
+		return fmt.Errorf("Cannot pay %d, customer owes %d", amount, balance)
+	}
+
+	if err := libraryService.CollectPayment(customerID, amount); err != nil {
+		return errors.Wrap(err, "Payment failed")
+	}
+	recordCirculationEntry(tx, &servicelib.AuditEntry{Action: servicelib.AuditActionCollectFee, Time: tx.now, CustomerID: customerID, Amount: amount, PerformedBy: tx.actor.ID})
+	publishEvent(tx, &events.FeeCollected{Time: tx.now, CustomerID: customerID, PayerID: customerID, BookIDs: []string{}, Amount: amount})
+
+	// Must manually register later, the money is already collected
+	if err := postLedgerEntry(ledger, createLedgerEntry(customerID, servicelib.LedgerEntryPayment, amount, nil, tx.now)); err != nil {
+		logDecision(tx, logging.LevelError, "Posting payment failed", logging.CustomerID(customerID), logging.Int("amount", amount), logging.Error(err))
+		return fmt.Errorf("Posting payment failed, manually register payment of %d for customer %d", amount, customerID)
+	}
+	return nil
+}
+
+// RefundPayment registers money paid back to customer
+func RefundPayment(actor servicelib.Actor, customerID int, amount int, ledger servicelib.Ledger, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionRefund); err != nil {
+		return err
+	}
+
+	balance, err := GetBalance(customerID, ledger)
+	if err != nil {
+		return err
+	}
+
+	// Only credit can be paid back
+	if amount <= 0 || amount > -balance {
+		return fmt.Errorf("Cannot refund %d, customer has credit of %d", amount, -balance)
+	}
+
+	return postLedgerEntry(ledger, createLedgerEntry(customerID, servicelib.LedgerEntryRefund, amount, nil, tx.now))
+}
+
+func calculateBalance(entries []*servicelib.LedgerEntry) int {
+	balance := 0
+	for _, entry := range entries {
+		switch entry.Type {
+		case servicelib.LedgerEntryCharge, servicelib.LedgerEntryRefund:
+			balance += entry.Amount
+		case servicelib.LedgerEntryPayment, servicelib.LedgerEntryWaiver:
+			balance -= entry.Amount
+		}
+	}
+	return balance
+}
+
+func chargeLateReturns(tx *transaction, customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+	debtor, err := findDebtor(tx, customer, notReturnedBookLends, libraryService)
+	if err != nil {
+		return err
+	}
+
+	priceToPay := calculateTotalPriceForLateReturn(customer, notReturnedBookLends, tx.now)
+	if err := validateDebtLimitNotExceeded(tx, debtor, priceToPay); err != nil {
+		return err
+	}
+
+	if priceToPay > 0 {
+		if err := chargeLateFees(tx, customer, debtor, notReturnedBookLends, priceToPay); err != nil {
+			return err
+		}
+
+		if err := renewBookLends(tx, customer, notReturnedBookLends, libraryService); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chargeLateFees(tx *transaction, customer *servicelib.Customer, debtor *servicelib.Customer, books []*servicelib.Book, priceToPay int) error {
+	charge := createLedgerEntry(debtor.ID, servicelib.LedgerEntryCharge, priceToPay, books, tx.now)
+	if err := postLedgerEntry(tx.ledger, charge); err != nil {
+		return err
+	}
+	recordCirculation(tx, servicelib.AuditActionChargeFee, customer, books, priceToPay)
+	return nil
+}
+
+func findDebtor(tx *transaction, customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, libraryService servicelib.LibraryService) (*servicelib.Customer, error) {
+	if len(notReturnedBookLends) == 0 {
+		return customer, nil
+	}
+
+	return findPayingCustomer(tx, customer, notReturnedBookLends, libraryService)
+}
+
+func validateDebtLimitNotExceeded(tx *transaction, debtor *servicelib.Customer, priceToPay int) error {
+	balance, err := GetBalance(debtor.ID, tx.ledger)
+	if err != nil {
+		return err
+	}
+
+	exceeded := balance+priceToPay > tx.debtPolicy.MaxBalance
+	traceRule(tx, RuleFeeTotal, !exceeded, map[string]interface{}{"amount": priceToPay, "balance": balance, "maxBalance": tx.debtPolicy.MaxBalance})
+	if exceeded {
+		return lendingErrorf(CodeDebtLimitReached, 0, "Customer %d owes %d, %d is the limit", debtor.ID, balance+priceToPay, tx.debtPolicy.MaxBalance)
+	}
+	return nil
+}
+
+func createLedgerEntry(customerID int, entryType servicelib.LedgerEntryType, amount int, books []*servicelib.Book, now time.Time) *servicelib.LedgerEntry {
+	return &servicelib.LedgerEntry{
+		CustomerID: customerID,
+		Type:       entryType,
+		Amount:     amount,
+		Time:       now,
+		BookIDs:    getBookIDs(books),
+	}
+}
+
+func postLedgerEntry(ledger servicelib.Ledger, entry *servicelib.LedgerEntry) error {
+	if err := ledger.AddLedgerEntry(entry); err != nil {
+		return errors.Wrapf(err, "Posting %s failed", entry.Type)
+	}
+	return nil
+}
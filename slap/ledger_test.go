@@ -0,0 +1,184 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetBalance(t *testing.T) {
+	customerID := 123456
+
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{
+		&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 100},
+		&servicelib.LedgerEntry{Type: servicelib.LedgerEntryPayment, Amount: 30},
+		&servicelib.LedgerEntry{Type: servicelib.LedgerEntryWaiver, Amount: 20},
+		&servicelib.LedgerEntry{Type: servicelib.LedgerEntryRefund, Amount: 5},
+	}, nil)
+
+	balance, err := GetBalance(customerID, ledger)
+	assert.Nil(t, err)
+	assert.Equal(t, 55, balance)
+
+	ledger.AssertExpectations(t)
+}
+
+func TestGetProjectedFees(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	customer := &servicelib.Customer{ID: 123456, Age: 20, Tier: servicelib.TierStudent}
+	overdue := &servicelib.Book{ID: "11111", DayPenalty: 10, CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, -3)}}
+	notDue := &servicelib.Book{ID: "22222", DayPenalty: 10, CurrentLend: &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, 3)}}
+
+	assert.Equal(t, 23, GetProjectedFees(customer, []*servicelib.Book{overdue, notDue}, now))
+	assert.Equal(t, 3, GetDaysLate(overdue, now))
+}
+
+func TestLendSucceedsPostingLateFee(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+	expectedCharge := &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryCharge, Amount: 20, Time: now, BookIDs: []string{nonReturnedBook.ID}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 50}}, nil)
+	ledger.On("AddLedgerEntry", expectedCharge).Return(nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLedger(ledger))
+	assert.Nil(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 7), nonReturnedBook.CurrentLend.LatestReturnDate)
+
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestLendFailsOwingTooMuch(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		balance     int
+		daysLate    int
+		expectedErr string
+	}{
+		{101, 0, fmt.Sprintf("Customer %d owes 101, 100 is the limit", customerID)},
+		{90, 2, fmt.Sprintf("Customer %d owes 110, 100 is the limit", customerID)},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+		customerLends := []*servicelib.Book{}
+		if tt.daysLate > 0 {
+			customerLends = append(customerLends, &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -tt.daysLate)}})
+		}
+		customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return(customerLends, nil)
+		ledger := new(mocks.Ledger)
+		ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: tt.balance}}, nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLedger(ledger))
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+		ledger.AssertExpectations(t)
+	}
+}
+
+func TestPayBalancePartially(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("CollectPayment", customerID, 30).Return(nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 50}}, nil)
+	ledger.On("AddLedgerEntry", &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryPayment, Amount: 30, Time: now, BookIDs: []string{}}).Return(nil)
+
+	err := PayBalance(librarian, customerID, 30, libraryService, ledger, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestPayBalanceFails(t *testing.T) {
+	customerID := 123456
+	expectedErr := fmt.Errorf("DB error")
+
+	testCases := []struct {
+		actor       servicelib.Actor
+		amount      int
+		paymentErr  error
+		postErr     error
+		expectedErr string
+	}{
+		{kiosk, 50, nil, nil, "Permission denied, kiosk kiosk-1 is not permitted to collect payment"},
+		{librarian, 0, nil, nil, "Cannot pay 0, customer owes 50"},
+		{librarian, 51, nil, nil, "Cannot pay 51, customer owes 50"},
+		{librarian, 50, expectedErr, nil, fmt.Sprintf("Payment failed: %s", expectedErr.Error())},
+		// Collected, but not on the ledger
+		{librarian, 50, nil, expectedErr, "Posting payment failed, manually register payment of 50 for customer 123456"},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		if tt.paymentErr != nil || tt.postErr != nil {
+			libraryService.On("CollectPayment", customerID, tt.amount).Return(tt.paymentErr)
+		}
+		ledger := new(mocks.Ledger)
+		ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 50}}, nil).Maybe()
+		if tt.postErr != nil {
+			ledger.On("AddLedgerEntry", mock.AnythingOfType("*servicelib.LedgerEntry")).Return(tt.postErr)
+		}
+
+		err := PayBalance(tt.actor, customerID, tt.amount, libraryService, ledger)
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+		ledger.AssertExpectations(t)
+	}
+}
+
+func TestRefundPayment(t *testing.T) {
+	customerID := 123456
+
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryPayment, Amount: 40}}, nil)
+	ledger.On("AddLedgerEntry", mock.AnythingOfType("*servicelib.LedgerEntry")).Return(nil)
+
+	err := RefundPayment(supervisor, customerID, 40, ledger)
+	assert.Nil(t, err)
+
+	err = RefundPayment(supervisor, customerID, 41, ledger)
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot refund 41, customer has credit of 40", err.Error())
+
+	err = RefundPayment(librarian, customerID, 40, ledger)
+	assert.Error(t, err)
+	assert.Equal(t, "Permission denied, staff ola is not permitted to refund", err.Error())
+
+	ledger.AssertExpectations(t)
+}
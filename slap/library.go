@@ -6,49 +6,97 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/notification"
 	"github.com/eirikbell/slap/servicelib"
 	"github.com/pkg/errors"
 )
 
-// LendBook handles the transaction of lending a book to a customer
-func LendBook(bookID string, customerID int, libraryService servicelib.LibraryService) error {
-	book, isRenewal, err := findBookDetails(bookID, customerID, libraryService)
+// LendBook handles the transaction of lending a book to a customer at branch
+func LendBook(actor servicelib.Actor, branch string, bookID string, customerID int, libraryService servicelib.LibraryService, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor), atBranch(branch))
+	startTrace(tx, "LendBook", bookID, customerID)
+	err := lendOrRenew(tx, bookID, customerID, libraryService)
 	if err != nil {
+		publishLendRejected(tx, bookID, customerID, err)
+	}
+	endTrace(tx, err)
+	return err
+}
+
+func lendOrRenew(tx *transaction, bookID string, customerID int, libraryService servicelib.LibraryService) error {
+	if err := validateOverride(tx); err != nil {
 		return err
 	}
 
-	customer, err := findActiveCustomer(customerID, libraryService)
+	var book *servicelib.Book
+	var isRenewal bool
+	err := traceStage(tx, "findBookDetails", libraryService, func(libraryService servicelib.LibraryService) (err error) {
+		book, isRenewal, err = findBookDetails(tx, bookID, customerID, libraryService)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	err = handleReturns(customer, isRenewal, libraryService)
+	if err := authorizeLendOrRenew(tx, isRenewal); err != nil {
+		return err
+	}
+
+	var customer *servicelib.Customer
+	var bookLends []*servicelib.Book
+	err = traceStage(tx, "findActiveCustomer", libraryService, func(libraryService servicelib.LibraryService) (err error) {
+		customer, bookLends, err = findActiveCustomer(tx, customerID, book, isRenewal, libraryService)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	return lendOrRenewBook(customer, book, isRenewal, libraryService)
+	err = traceStage(tx, "handleReturns", libraryService, func(libraryService servicelib.LibraryService) error {
+		return handleReturns(tx, customer, bookLends, libraryService)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Decided once the fees are settled, settling them is what lifts the lock
+	if defersAutomaticLock(tx) {
+		if err := validateAccountStatus(tx, customer, bookLends); err != nil {
+			return err
+		}
+	}
+
+	return traceStage(tx, "lendOrRenewBook", libraryService, func(libraryService servicelib.LibraryService) error {
+		return lendOrRenewBook(tx, customer, book, isRenewal, libraryService)
+	})
 }
 
-func findBookDetails(bookID string, customerID int, libraryService servicelib.LibraryService) (*servicelib.Book, bool, error) {
-	book, err := findBook(bookID, libraryService)
+func findBookDetails(tx *transaction, bookID string, customerID int, libraryService servicelib.LibraryService) (*servicelib.Book, bool, error) {
+	book, err := findBook(tx, bookID, libraryService)
 	if err != nil {
 		return nil, false, err
 	}
 
-	isRenewal, err := isisRenewal(book, customerID)
+	isRenewal, err := isisRenewal(tx, book, customerID)
 	if err != nil {
 		return nil, false, err
 	}
 
+	if err := validateNotInTransit(tx, book); err != nil {
+		return nil, false, err
+	}
+
 	return book, isRenewal, nil
 }
 
-func findBook(bookID string, libraryService servicelib.LibraryService) (*servicelib.Book, error) {
+func findBook(tx *transaction, bookID string, libraryService servicelib.LibraryService) (*servicelib.Book, error) {
 	var b *servicelib.Book
 	// Check book is lendable
+	traceRule(tx, RuleBookIDValid, len(bookID) >= 5, map[string]interface{}{"bookId": bookID, "length": len(bookID), "minLength": 5})
 	if len(bookID) < 5 {
-		return nil, fmt.Errorf("Book not found")
+		return nil, lendingErrorf(CodeBookNotFound, 0, "Book not found")
 	}
 
 	b = libraryService.GetBook(bookID)
@@ -59,145 +107,238 @@ func findBook(bookID string, libraryService servicelib.LibraryService) (*service
 	olddb := libraryService.GetOldDbBooks()
 	for _, ob := range olddb {
 		if ob.ID == bookID {
+			logDecision(tx, logging.LevelInfo, "Book found in old database", logging.BookID(bookID))
 			return ob, nil
 		}
 	}
 
-	return nil, fmt.Errorf("Book not found")
+	logDecision(tx, logging.LevelInfo, "Book not found", logging.BookID(bookID))
+	traceRule(tx, RuleBookAvailable, false, map[string]interface{}{"found": false})
+	return nil, lendingErrorf(CodeBookNotFound, 0, "Book not found")
 }
 
-func isisRenewal(book *servicelib.Book, customerID int) (bool, error) {
+func isisRenewal(tx *transaction, book *servicelib.Book, customerID int) (bool, error) {
+	traceBookAvailable(tx, book, customerID)
 	if book.CurrentLend != nil {
 		if book.CurrentLend.CustomerID != customerID {
-			return false, fmt.Errorf("Book is currently lended to customer %d", book.CurrentLend.CustomerID)
+			return false, lendingErrorf(CodeBookLended, 0, "Book is currently lended to customer %d", book.CurrentLend.CustomerID)
 		}
 
+		logDecision(tx, logging.LevelInfo, "Renewal detected", logging.BookID(book.ID), logging.CustomerID(customerID), logging.Int("renewals", book.CurrentLend.Renewals))
 		return true, nil
 	}
 	return false, nil
 }
 
-func handleReturns(customer *servicelib.Customer, isRenewal bool, libraryService servicelib.LibraryService) error {
-	notReturnedBookLends, err := getNotReturnedBookLends(customer, isRenewal, libraryService)
+func traceBookAvailable(tx *transaction, book *servicelib.Book, customerID int) {
+	lentTo := 0
+	if book.CurrentLend != nil {
+		lentTo = book.CurrentLend.CustomerID
+	}
+	traceRule(tx, RuleBookAvailable, lentTo == 0 || lentTo == customerID, map[string]interface{}{"found": true, "lentToCustomerId": lentTo})
+}
+
+func handleReturns(tx *transaction, customer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+	notReturnedBookLends := filterNotReturnedBookLends(bookLends, tx.now)
+
+	if isFeeWaived(tx) {
+		return waiveLateReturns(tx, customer, notReturnedBookLends, libraryService)
+	}
+
+	if tx.ledger != nil {
+		return chargeLateReturns(tx, customer, notReturnedBookLends, libraryService)
+	}
+
+	return collectPayment(tx, customer, notReturnedBookLends, libraryService)
+}
+
+func findActiveCustomer(tx *transaction, customerID int, book *servicelib.Book, isRenewal bool, libraryService servicelib.LibraryService) (*servicelib.Customer, []*servicelib.Book, error) {
+	customer, err := findCustomer(tx, customerID, libraryService)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	return collectPayment(customer, notReturnedBookLends, libraryService)
+	bookLends, err := getBookLends(customer, libraryService)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Nothing is posted for a locked account
+	if !defersAutomaticLock(tx) {
+		if err := validateAccountStatus(tx, customer, bookLends); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ctx := &EligibilityContext{Customer: withoutAutomaticLock(customer), Book: book, BookLends: bookLends, IsRenewal: isRenewal, Now: tx.now, Policy: GetTierPolicy(customer)}
+	if err := validateEligibility(tx, ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return customer, bookLends, nil
 }
 
-func findActiveCustomer(customerID int, libraryService servicelib.LibraryService) (*servicelib.Customer, error) {
+func findCustomer(tx *transaction, customerID int, libraryService servicelib.LibraryService) (*servicelib.Customer, error) {
 	customer, err := libraryService.GetCustomer(customerID)
 	if err != nil {
-		return nil, errors.Wrap(err, "Customer not found")
+		return nil, wrapLendingError(err, CodeCustomerNotFound, "Customer not found")
 	}
 
-	if customer.IsLocked {
-		return nil, fmt.Errorf("Customer account is locked")
+	// Only staff can unlock, no need to look further
+	if isManuallyLocked(customer) {
+		traceAccountNotLocked(tx, customer)
+		return nil, createAccountLockedError(customer)
 	}
 
 	return customer, nil
 }
 
-func getNotReturnedBookLends(customer *servicelib.Customer, isRenewal bool, libraryService servicelib.LibraryService) ([]*servicelib.Book, error) {
+func getBookLends(customer *servicelib.Customer, libraryService servicelib.LibraryService) ([]*servicelib.Book, error) {
 	bookLends, err := libraryService.GetLendsForCustomer(customer.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "Cannot retrieve current lends")
 	}
-
-	if err := validateLendingLimitNotExceeded(bookLends, isRenewal); err != nil {
-		return nil, err
-	}
-
-	return filterNotReturnedBookLends(bookLends), nil
+	return bookLends, nil
 }
 
-func validateLendingLimitNotExceeded(bookLends []*servicelib.Book, isRenewal bool) error {
-	// Used to be more
-	if len(bookLends) >= 3 {
+func validateLendingLimitNotExceeded(bookLends []*servicelib.Book, isRenewal bool, policy TierPolicy) error {
+	if len(bookLends) >= policy.MaxLends {
 		if !isRenewal {
-			return fmt.Errorf("Customer already has %d lended books, 3 is the limit", len(bookLends))
+			return lendingErrorf(CodeLendLimitReached, len(bookLends), "Customer already has %d lended books, %d is the limit", len(bookLends), policy.MaxLends)
 		}
 
-		// Trying to bring down outstanding books, but allow renewal if 3 other outstanding books
-		if len(bookLends) >= 4 {
-			return fmt.Errorf("Cannot renew when more than 3 other books are lended, customer already has %d lended books", len(bookLends))
+		// Trying to bring down outstanding books, but allow renewal if limit is reached by other outstanding books
+		if len(bookLends) >= policy.MaxLends+1 {
+			return lendingErrorf(CodeRenewLimitReached, len(bookLends), "Cannot renew when more than %d other books are lended, customer already has %d lended books", policy.MaxLends, len(bookLends))
 		}
 	}
 	return nil
 }
 
-func filterNotReturnedBookLends(bookLends []*servicelib.Book) []*servicelib.Book {
+func filterNotReturnedBookLends(bookLends []*servicelib.Book, now time.Time) []*servicelib.Book {
 	notReturnedBookLends := []*servicelib.Book{}
 	for _, l := range bookLends {
-		if l.CurrentLend.LatestReturnDate.Before(time.Now()) {
+		if l.CurrentLend != nil && l.CurrentLend.LatestReturnDate.Before(now) {
 			notReturnedBookLends = append(notReturnedBookLends, l)
 		}
 	}
 	return notReturnedBookLends
 }
 
-func collectPayment(customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+func collectPayment(tx *transaction, customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
 	if len(notReturnedBookLends) == 0 {
 		return nil
 	}
 
-	if err := canCollectPayment(customer, notReturnedBookLends); err != nil {
+	payer, err := findPayingCustomer(tx, customer, notReturnedBookLends, libraryService)
+	if err != nil {
 		return err
 	}
 
-	return payAndRenewBookLends(customer, notReturnedBookLends, libraryService)
+	return payAndRenewBookLends(tx, customer, payer, notReturnedBookLends, libraryService)
+}
+
+func findPayingCustomer(tx *transaction, customer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) (*servicelib.Customer, error) {
+	err := canCollectPayment(customer, bookLends, tx.now)
+	traceRule(tx, RuleUnderagePayment, err == nil, map[string]interface{}{
+		"age":          customer.AgeAt(tx.now),
+		"minimumAge":   minAgeToPay,
+		"guardianPays": isFinesRoutedToGuardian(customer, tx.now),
+	})
+	if err != nil {
+		logDecision(tx, logging.LevelWarn, "Payment blocked", logging.CustomerID(customer.ID), logging.PII("age", customer.AgeAt(tx.now)), logging.Int("books", len(bookLends)))
+		recordCirculation(tx, servicelib.AuditActionPaymentBlocked, customer, bookLends, calculateTotalPriceForLateReturn(customer, bookLends, tx.now))
+		return nil, err
+	}
+
+	if !isFinesRoutedToGuardian(customer, tx.now) {
+		return customer, nil
+	}
+
+	return findGuardian(customer, libraryService)
 }
 
-func canCollectPayment(customer *servicelib.Customer, bookLends []*servicelib.Book) error {
-	// Not allowed by law to collect payment if customer is younger than 13
-	if customer.Age < 13 {
-		return fmt.Errorf("Cannot collect payment for %d books, customer is younger than 13", len(bookLends))
+// Not allowed by law to collect payment from younger customers
+const minAgeToPay = 13
+
+func canCollectPayment(customer *servicelib.Customer, bookLends []*servicelib.Book, now time.Time) error {
+	// Unless a guardian pays
+	if customer.AgeAt(now) < minAgeToPay && !isFinesRoutedToGuardian(customer, now) {
+		return lendingErrorf(CodeTooYoungToPay, len(bookLends), "Cannot collect payment for %d books, customer is younger than 13", len(bookLends))
 	}
 	return nil
 }
 
-func payAndRenewBookLends(customer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
-	priceToPay := calculateTotalPriceForLateReturn(customer, bookLends)
+func isFinesRoutedToGuardian(customer *servicelib.Customer, now time.Time) bool {
+	return customer.AgeAt(now) < 18 && customer.GuardianID != 0 && customer.GuardianConsent.PayFines
+}
 
-	if priceToPay > 0 {
-		if err := libraryService.CollectPayment(customer.ID, priceToPay); err != nil {
-			return errors.Wrap(err, "Payment failed")
-		}
+func payAndRenewBookLends(tx *transaction, customer *servicelib.Customer, payer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+	priceToPay, err := payLateFees(tx, customer, payer, bookLends, libraryService)
+	if err != nil || priceToPay == 0 {
+		return err
+	}
 
-		if err := renewBookLends(customer, bookLends, libraryService); err != nil {
-			return err
+	return renewBookLends(tx, customer, bookLends, libraryService)
+}
+
+func payLateFees(tx *transaction, customer *servicelib.Customer, payer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) (int, error) {
+	priceToPay := calculateTotalPriceForLateReturn(customer, bookLends, tx.now)
+
+	logDecision(tx, logging.LevelInfo, "Late fee calculated", logging.CustomerID(customer.ID), logging.Int("payerId", payer.ID),
+		logging.Int("books", len(bookLends)), logging.Int("amount", priceToPay))
+	traceRule(tx, RuleFeeTotal, true, map[string]interface{}{"amount": priceToPay, "books": len(bookLends), "payerId": payer.ID})
+	if priceToPay > 0 {
+		if err := libraryService.CollectPayment(payer.ID, priceToPay); err != nil {
+			logDecision(tx, logging.LevelError, "Payment failed", logging.CustomerID(customer.ID), logging.Int("amount", priceToPay), logging.Error(err))
+			return 0, wrapLendingError(err, CodePaymentFailed, "Payment failed")
 		}
+		recordCirculation(tx, servicelib.AuditActionCollectFee, customer, bookLends, priceToPay)
+		publishEvent(tx, &events.FeeCollected{Time: tx.now, CustomerID: customer.ID, PayerID: payer.ID, BookIDs: getBookIDs(bookLends), Amount: priceToPay})
 	}
-	return nil
+	return priceToPay, nil
 }
 
-func calculateTotalPriceForLateReturn(customer *servicelib.Customer, bookLends []*servicelib.Book) int {
+func calculateTotalPriceForLateReturn(customer *servicelib.Customer, bookLends []*servicelib.Book, now time.Time) int {
+	policy := GetTierPolicy(customer)
+
 	tot := 0
 	for _, nr := range bookLends {
-		price := calculatePriceForLateReturn(nr)
+		price := calculatePriceForLateReturn(nr, now)
 		tot += price
 	}
-	if customer.Age < 18 {
+	tot = applyPercent(tot, policy.FeeRate)
+	return applyPercent(tot, 100-calculateDiscount(customer, policy, now))
+}
+
+func calculateDiscount(customer *servicelib.Customer, policy TierPolicy, now time.Time) int {
+	if customer.AgeAt(now) < 18 && policy.Discount < 50 {
 		// 50% less if not adult
-		tot = int(math.Ceil(float64(tot) / float64(2)))
+		return 50
 	}
-	return tot
+	return policy.Discount
 }
 
-func calculatePriceForLateReturn(book *servicelib.Book) int {
-	late := time.Since(book.CurrentLend.LatestReturnDate)
-	days := int(math.Ceil(late.Hours() / 24))
+func applyPercent(price int, percent int) int {
+	return int(math.Ceil(float64(price*percent) / float64(100)))
+}
 
-	return days * book.DayPenalty
+func calculatePriceForLateReturn(book *servicelib.Book, now time.Time) int {
+	return calculateDaysLate(book, now) * getDayPenalty(book)
 }
 
-func renewBookLends(customer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+func calculateDaysLate(book *servicelib.Book, now time.Time) int {
+	late := now.Sub(book.CurrentLend.LatestReturnDate)
+	return int(math.Ceil(late.Hours() / 24))
+}
+
+func renewBookLends(tx *transaction, customer *servicelib.Customer, bookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
 	fail := []string{}
 	for _, book := range bookLends {
-		setBookLendLatestReturnDate(book.CurrentLend)
+		setBookLendLatestReturnDate(tx, book, GetTierPolicy(customer))
 		// Must manually register later
 		if err := libraryService.SaveBook(book); err != nil {
+			logDecision(tx, logging.LevelError, "Saving extended date failed", logging.BookID(book.ID), logging.CustomerID(customer.ID), logging.Error(err))
 			fail = append(fail, book.ID)
 		}
 	}
@@ -207,43 +348,82 @@ func renewBookLends(customer *servicelib.Customer, bookLends []*servicelib.Book,
 	return nil
 }
 
-func lendOrRenewBook(customer *servicelib.Customer, book *servicelib.Book, isRenewal bool, libraryService servicelib.LibraryService) error {
+func getBookIDs(books []*servicelib.Book) []string {
+	bookIDs := []string{}
+	for _, book := range books {
+		bookIDs = append(bookIDs, book.ID)
+	}
+	return bookIDs
+}
+
+func lendOrRenewBook(tx *transaction, customer *servicelib.Customer, book *servicelib.Book, isRenewal bool, libraryService servicelib.LibraryService) error {
 	if isRenewal {
-		return renewBook(book, libraryService)
+		if err := validateDeskRenewal(tx, customer, book); err != nil {
+			return err
+		}
+		return renewBook(tx, customer, book, GetTierPolicy(customer), libraryService)
 	}
 
-	return lendBook(book, customer.ID, libraryService)
+	return lendBook(tx, book, customer, libraryService)
 }
 
-func lendBook(book *servicelib.Book, customerID int, libraryService servicelib.LibraryService) error {
-	book.CurrentLend = createBookLend(customerID, book.ID)
+func lendBook(tx *transaction, book *servicelib.Book, customer *servicelib.Customer, libraryService servicelib.LibraryService) error {
+	book.CurrentLend = createBookLend(tx, customer.ID, book)
+	setBookLendLatestReturnDate(tx, book, GetTierPolicy(customer))
+	recordLocation(tx, book)
 	// Lend registration failed
 	if err := libraryService.SaveBook(book); err != nil {
-		return errors.Wrap(err, "Lend failed")
+		logDecision(tx, logging.LevelError, "Saving lend failed", logging.BookID(book.ID), logging.CustomerID(customer.ID), logging.Error(err))
+		return wrapLendingError(err, CodeLendFailed, "Lend failed")
 	}
 
+	recordCirculation(tx, servicelib.AuditActionLend, customer, []*servicelib.Book{book}, 0)
+	publishEvent(tx, &events.BookLent{Time: tx.now, CustomerID: customer.ID, BookID: book.ID, LatestReturnDate: book.CurrentLend.LatestReturnDate, PerformedBy: tx.actor.ID})
+	sendLendReceipt(tx, customer, book)
 	return nil
 }
 
-func renewBook(book *servicelib.Book, libraryService servicelib.LibraryService) error {
-	setBookLendLatestReturnDate(book.CurrentLend)
+func sendLendReceipt(tx *transaction, customer *servicelib.Customer, book *servicelib.Book) {
+	if tx.sender == nil {
+		return
+	}
+
+	data := &notification.Data{
+		CustomerID:       customer.ID,
+		BookID:           book.ID,
+		LatestReturnDate: book.CurrentLend.LatestReturnDate,
+	}
+	// The book is lended, a missing receipt does not undo it
+	_ = tx.sender.Send(customer, notification.KindLendReceipt, data)
+}
+
+func renewBook(tx *transaction, customer *servicelib.Customer, book *servicelib.Book, policy TierPolicy, libraryService servicelib.LibraryService) error {
+	setBookLendLatestReturnDate(tx, book, policy)
+	book.CurrentLend.Renewals++
+	recordLocation(tx, book)
 	// Must manually refund
 	if err := libraryService.SaveBook(book); err != nil {
-		return errors.Wrap(err, "Renewal failed")
+		logDecision(tx, logging.LevelError, "Saving renewal failed", logging.BookID(book.ID), logging.CustomerID(customer.ID), logging.Error(err))
+		return wrapLendingError(err, CodeRenewalFailed, "Renewal failed")
 	}
+
+	recordCirculation(tx, servicelib.AuditActionRenew, customer, []*servicelib.Book{book}, 0)
+	publishEvent(tx, &events.LendRenewed{Time: tx.now, CustomerID: customer.ID, BookID: book.ID, LatestReturnDate: book.CurrentLend.LatestReturnDate, Renewals: book.CurrentLend.Renewals, PerformedBy: tx.actor.ID})
 	return nil
 }
 
-func createBookLend(customerID int, bookID string) *servicelib.Lend {
-	lend := &servicelib.Lend{
+func createBookLend(tx *transaction, customerID int, book *servicelib.Book) *servicelib.Lend {
+	return &servicelib.Lend{
 		CustomerID: customerID,
-		BookID:     bookID,
+		BookID:     book.ID,
+		LentAt:     tx.branch,
+		DueBackAt:  getDueBackAt(tx, book),
 	}
-	setBookLendLatestReturnDate(lend)
-	return lend
 }
 
-func setBookLendLatestReturnDate(lend *servicelib.Lend) {
-	d := time.Now().AddDate(0, 0, 7)
-	lend.LatestReturnDate = d
+// Loan period of the branch the book was lended at, opening days of the branch it is returned to
+func setBookLendLatestReturnDate(tx *transaction, book *servicelib.Book, policy TierPolicy) {
+	d := tx.now.AddDate(0, 0, getLoanDays(book, policy, tx.branches.PolicyFor(book.CurrentLend.LentAt)))
+	calendar := tx.branches.PolicyFor(book.CurrentLend.DueBackAt).Calendar
+	book.CurrentLend.LatestReturnDate = calendar.NextOpenDay(d)
 }
@@ -7,10 +7,18 @@ import (
 	"time"
 
 	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/notification"
 	"github.com/eirikbell/slap/servicelib"
 	"github.com/stretchr/testify/assert"
 )
 
+var librarian = servicelib.Actor{Type: servicelib.ActorTypeStaff, ID: "ola", Roles: []servicelib.Role{servicelib.RoleLibrarian}}
+var supervisor = servicelib.Actor{Type: servicelib.ActorTypeStaff, ID: "kari", Roles: []servicelib.Role{servicelib.RoleSupervisor}}
+var administrator = servicelib.Actor{Type: servicelib.ActorTypeStaff, ID: "per", Roles: []servicelib.Role{servicelib.RoleAdministrator}}
+var kiosk = servicelib.Actor{Type: servicelib.ActorTypeKiosk, ID: "kiosk-1", Roles: []servicelib.Role{servicelib.RoleSelfService}}
+
+const branch = "sentrum"
+
 func TestFoo(t *testing.T) {
 	testCases := []struct {
 		houres       float64
@@ -44,7 +52,7 @@ func TestShortIdNotFound(t *testing.T) {
 	for _, tt := range testCases {
 		libraryService := new(mocks.LibraryService)
 
-		err := LendBook(tt.bookID, 123456, libraryService)
+		err := LendBook(librarian, branch, tt.bookID, 123456, libraryService)
 		assert.Error(t, err)
 		assert.Equal(t, "Book not found", err.Error())
 
@@ -65,7 +73,7 @@ func TestBookNotFound(t *testing.T) {
 		libraryService.On("GetBook", tt.bookID).Return(nil)
 		libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{&servicelib.Book{ID: "54321"}, &servicelib.Book{ID: "65432"}})
 
-		err := LendBook(tt.bookID, 123456, libraryService)
+		err := LendBook(librarian, branch, tt.bookID, 123456, libraryService)
 		assert.Error(t, err)
 		assert.Equal(t, "Book not found", err.Error())
 
@@ -83,7 +91,7 @@ func TestLendedByOtherCustomer(t *testing.T) {
 	libraryService := new(mocks.LibraryService)
 	libraryService.On("GetBook", bookID).Return(book)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Book is currently lended to customer %d", otherCustomerID), err.Error())
 
@@ -101,7 +109,7 @@ func TestCustomerNotFound(t *testing.T) {
 	libraryService.On("GetBook", bookID).Return(book)
 	libraryService.On("GetCustomer", customerID).Return(nil, expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Customer not found: %s", expectedErr.Error()), err.Error())
 
@@ -119,7 +127,7 @@ func TestCustomerAccountLocked(t *testing.T) {
 	libraryService.On("GetBook", bookID).Return(book)
 	libraryService.On("GetCustomer", customerID).Return(customer, nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, "Customer account is locked", err.Error())
 
@@ -139,7 +147,7 @@ func TestCannotRetrieveCustomerLends(t *testing.T) {
 	libraryService.On("GetCustomer", customerID).Return(customer, nil)
 	libraryService.On("GetLendsForCustomer", customerID).Return(nil, expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Cannot retrieve current lends: %s", expectedErr.Error()), err.Error())
 
@@ -167,7 +175,7 @@ func TestNotRenewTooManyLends(t *testing.T) {
 		libraryService.On("GetCustomer", customerID).Return(customer, nil)
 		libraryService.On("GetLendsForCustomer", customerID).Return(tt.customerLends, nil)
 
-		err := LendBook(bookID, customerID, libraryService)
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
 		assert.Error(t, err)
 		assert.Equal(t, fmt.Sprintf("Customer already has %d lended books, 3 is the limit", len(tt.customerLends)), err.Error())
 
@@ -196,7 +204,7 @@ func TestRenewTooManyLends(t *testing.T) {
 		libraryService.On("GetCustomer", customerID).Return(customer, nil)
 		libraryService.On("GetLendsForCustomer", customerID).Return(tt.customerLends, nil)
 
-		err := LendBook(bookID, customerID, libraryService)
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
 		assert.Error(t, err)
 		assert.Equal(t, fmt.Sprintf("Cannot renew when more than 3 other books are lended, customer already has %d lended books", len(tt.customerLends)), err.Error())
 
@@ -227,7 +235,7 @@ func TestTooYoungToCollectPayment(t *testing.T) {
 		libraryService.On("GetCustomer", customerID).Return(customer, nil)
 		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{&servicelib.Book{CurrentLend: &servicelib.Lend{LatestReturnDate: time.Now().Add(-1 * time.Minute)}}}, nil)
 
-		err := LendBook(bookID, customerID, libraryService)
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
 		assert.Error(t, err)
 		assert.Equal(t, fmt.Sprintf("Cannot collect payment for 1 books, customer is younger than 13"), err.Error())
 
@@ -235,6 +243,156 @@ func TestTooYoungToCollectPayment(t *testing.T) {
 	}
 }
 
+func TestTooYoungWithoutGuardianConsent(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	guardianID := 654321
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID}}
+
+	customer := &servicelib.Customer{ID: customerID, IsLocked: false, Age: 10, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{ViewLends: true}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{&servicelib.Book{CurrentLend: &servicelib.Lend{LatestReturnDate: time.Now().Add(-1 * time.Minute)}}}, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, "Cannot collect payment for 1 books, customer is younger than 13", err.Error())
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestGuardianNotFound(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	guardianID := 654321
+	expectedErr := fmt.Errorf("DB error")
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID}}
+
+	customer := &servicelib.Customer{ID: customerID, IsLocked: false, Age: 10, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{PayFines: true}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetCustomer", guardianID).Return(nil, expectedErr)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{&servicelib.Book{DayPenalty: 10, CurrentLend: &servicelib.Lend{LatestReturnDate: time.Now().Add(-1 * time.Minute)}}}, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("Guardian not found: %s", expectedErr.Error()), err.Error())
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestLockedGuardianNotCharged(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	guardianID := 654321
+
+	testCases := []*servicelib.Customer{
+		{ID: guardianID, Age: 40, IsLocked: true},
+		{ID: guardianID, Age: 40, IsLocked: true, LockReason: servicelib.LockReasonUnpaidFines},
+	}
+
+	for _, guardian := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+		customer := &servicelib.Customer{ID: customerID, Age: 10, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{PayFines: true}}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetCustomer", guardianID).Return(guardian, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{&servicelib.Book{DayPenalty: 10, CurrentLend: &servicelib.Lend{LatestReturnDate: time.Now().Add(-1 * time.Minute)}}}, nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
+		assert.Error(t, err)
+		assert.Equal(t, fmt.Sprintf("Guardian %d account is locked", guardianID), err.Error())
+		assert.Equal(t, "Kontoen til din foresatte er sperret, ta kontakt med bibliotekets ansatte", Localize(err, "nb"))
+		libraryService.AssertNotCalled(t, "CollectPayment", guardianID, 10)
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestGuardianPaysForYoungCustomer(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	guardianID := 654321
+
+	testCases := []struct {
+		age             int
+		expectedPayment int
+	}{
+		{0, 10},
+		{12, 10},
+		{16, 10},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: time.Now().AddDate(0, 0, 1)}}
+		nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 20, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: time.Now().Add(-1 * time.Minute)}}
+
+		customer := &servicelib.Customer{ID: customerID, IsLocked: false, Age: tt.age, GuardianID: guardianID, GuardianConsent: servicelib.GuardianConsent{PayFines: true}}
+		guardian := &servicelib.Customer{ID: guardianID, IsLocked: false, Age: 40}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetCustomer", guardianID).Return(guardian, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book, nonReturnedBook}, nil)
+		libraryService.On("CollectPayment", guardianID, tt.expectedPayment).Return(nil)
+		libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+		libraryService.On("SaveBook", book).Return(nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
+		assert.Nil(t, err)
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestAgeRelativeToTransactionTime(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	birthDate := time.Date(2006, time.September, 10, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		now             time.Time
+		expectedErr     string
+		expectedPayment int
+	}{
+		{time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC), "Cannot collect payment for 1 books, customer is younger than 13", 0},
+		{time.Date(2019, time.September, 10, 12, 0, 0, 0, time.UTC), "", 5},
+		{time.Date(2024, time.September, 10, 12, 0, 0, 0, time.UTC), "", 10},
+	}
+
+	for _, tt := range testCases {
+		now := tt.now
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+		nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.Add(-1 * time.Minute)}}
+
+		customer := &servicelib.Customer{ID: customerID, IsLocked: false, BirthDate: birthDate}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book, nonReturnedBook}, nil)
+		if tt.expectedPayment > 0 {
+			libraryService.On("CollectPayment", customerID, tt.expectedPayment).Return(nil)
+			libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+			libraryService.On("SaveBook", book).Return(nil)
+		}
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		if tt.expectedErr != "" {
+			assert.Error(t, err)
+			assert.Equal(t, tt.expectedErr, err.Error())
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, now.AddDate(0, 0, 7), book.CurrentLend.LatestReturnDate)
+		}
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
 func TestCannotCollectPayment(t *testing.T) {
 	bookID := "12345"
 	customerID := 123456
@@ -249,7 +407,7 @@ func TestCannotCollectPayment(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{&servicelib.Book{DayPenalty: 10, CurrentLend: &servicelib.Lend{LatestReturnDate: time.Now().Add(-1 * time.Minute)}}}, nil)
 	libraryService.On("CollectPayment", customerID, 10).Return(expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Payment failed: %s", expectedErr.Error()), err.Error())
 
@@ -275,7 +433,7 @@ func TestFailingExtendedLend(t *testing.T) {
 	libraryService.On("SaveBook", nonReturnedBook1).Return(expectedErr)
 	libraryService.On("SaveBook", nonReturnedBook2).Return(expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Saving extended date failed, manually register extension for customer %d on books %s, %s", customerID, nonReturnedBook1.ID, nonReturnedBook2.ID), err.Error())
 
@@ -296,7 +454,7 @@ func TestRenewalFails(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
 	libraryService.On("SaveBook", book).Return(expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Renewal failed: %s", expectedErr.Error()), err.Error())
 
@@ -317,7 +475,7 @@ func TestRenewalSucceeds(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -341,7 +499,7 @@ func TestRenewalSucceedsWithBookInOldDb(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -367,7 +525,7 @@ func TestRenewalSucceedsCollectPayment(t *testing.T) {
 	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -390,7 +548,7 @@ func TestLendFails(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
 	libraryService.On("SaveBook", book).Return(expectedErr)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Error(t, err)
 	assert.Equal(t, fmt.Sprintf("Lend failed: %s", expectedErr.Error()), err.Error())
 
@@ -411,7 +569,7 @@ func TestLendSucceeds(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -435,7 +593,7 @@ func TestLendSucceedsWithBookInOldDb(t *testing.T) {
 	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -461,7 +619,7 @@ func TestLendSucceedsCollectPayment(t *testing.T) {
 	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
 	libraryService.On("SaveBook", book).Return(nil)
 
-	err := LendBook(bookID, customerID, libraryService)
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
 	assert.Nil(t, err)
 
 	assert.NotEqual(t, oldDate, book.CurrentLend.LatestReturnDate)
@@ -470,6 +628,57 @@ func TestLendSucceedsCollectPayment(t *testing.T) {
 	libraryService.AssertExpectations(t)
 }
 
+func TestLendSendsReceipt(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+
+	customer := &servicelib.Customer{ID: customerID, Age: 20, Contact: servicelib.ContactPreferences{Phone: "+4799999999", Channels: []servicelib.Channel{servicelib.ChannelSMS}}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	sms := &notification.RecordingNotifier{}
+	dispatcher := notification.NewDispatcher(notification.NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelSMS, sms)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithReceipts(dispatcher))
+	assert.Nil(t, err)
+
+	assert.Len(t, sms.Messages, 1)
+	assert.Equal(t, notification.KindLendReceipt, sms.Messages[0].Kind)
+	assert.Equal(t, "+4799999999", sms.Messages[0].Address)
+	assert.Equal(t, "Book 12345 lent, return by 2019-09-16.", sms.Messages[0].Body)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendSucceedsWhenReceiptFails(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	email := &notification.RecordingNotifier{Err: fmt.Errorf("SMTP error")}
+	dispatcher := notification.NewDispatcher(notification.NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelEmail, email)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithReceipts(dispatcher))
+	assert.Nil(t, err)
+	assert.NotNil(t, book.CurrentLend)
+
+	libraryService.AssertExpectations(t)
+}
+
 func TestCollectPaymentMultiple(t *testing.T) {
 	bookID := "12345"
 	customerID := 123456
@@ -503,7 +712,7 @@ func TestCollectPaymentMultiple(t *testing.T) {
 		libraryService.On("SaveBook", nonReturnedBook2).Return(nil)
 		libraryService.On("SaveBook", book).Return(nil)
 
-		err := LendBook(bookID, customerID, libraryService)
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
 		assert.Nil(t, err)
 
 		libraryService.AssertExpectations(t)
@@ -543,7 +752,7 @@ func TestCollectPaymentMultipleYoungCustomer(t *testing.T) {
 		libraryService.On("SaveBook", nonReturnedBook2).Return(nil)
 		libraryService.On("SaveBook", book).Return(nil)
 
-		err := LendBook(bookID, customerID, libraryService)
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
 		assert.Nil(t, err)
 
 		libraryService.AssertExpectations(t)
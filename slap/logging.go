@@ -0,0 +1,15 @@
+package tldr
+
+import "github.com/eirikbell/slap/logging"
+
+// Customer data is marked as such in the fields, the logger decides whether to redact it
+func logDecision(tx *transaction, level logging.Level, message string, fields ...logging.Field) {
+	if tx.logger == nil {
+		return
+	}
+
+	if tx.quote {
+		fields = append(fields, logging.Bool("quote", true))
+	}
+	tx.logger.Log(level, message, fields...)
+}
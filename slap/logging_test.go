@@ -0,0 +1,97 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLendBookLogsDecisions(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(nil)
+	libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{book})
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(fmt.Errorf("DB error"))
+
+	logger := &logging.RecordingLogger{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLogger(logger))
+	assert.Error(t, err)
+
+	messages := []string{}
+	for _, entry := range logger.Entries {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"Book found in old database", "Eligibility rule evaluated", "Eligibility rule evaluated", "Eligibility rule evaluated", "Eligibility rule evaluated", "Late fee calculated", "Saving extended date failed"}, messages)
+
+	limit := logger.Entries[4]
+	assert.Equal(t, logging.LevelDebug, limit.Level)
+	assert.Equal(t, "lending-limit", limit.Field("rule"))
+	assert.Equal(t, true, limit.Field("passed"))
+	assert.Equal(t, 20, logger.Find("Late fee calculated").Field("amount"))
+	saveFailed := logger.Find("Saving extended date failed")
+	assert.Equal(t, logging.LevelError, saveFailed.Level)
+	assert.Equal(t, "654321", saveFailed.Field("bookId"))
+	assert.Equal(t, "DB error", saveFailed.Field("error"))
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookLogsRenewal(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: 1}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	logger := &logging.RecordingLogger{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLogger(logger))
+	assert.Nil(t, err)
+
+	renewal := logger.Find("Renewal detected")
+	assert.Equal(t, bookID, renewal.Field("bookId"))
+	assert.Equal(t, customerID, renewal.Field("customerId"))
+	assert.Equal(t, 1, renewal.Field("renewals"))
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookLogsBlockedPaymentWithoutAge(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -3)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 10}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+
+	logger := &logging.RecordingLogger{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLogger(logger))
+	assert.Error(t, err)
+
+	blocked := logger.Find("Payment blocked")
+	assert.Equal(t, logging.LevelWarn, blocked.Level)
+	assert.Equal(t, logging.Redacted, blocked.Field("age"))
+	assert.Equal(t, 1, blocked.Field("books"))
+}
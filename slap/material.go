@@ -0,0 +1,90 @@
+package tldr
+
+import "github.com/eirikbell/slap/servicelib"
+
+// Renewals of a material limited only by the tier of the customer
+const tierRenewals = -1
+
+// MaterialPolicy lending rules of a material type
+type MaterialPolicy struct {
+	// Loan period in days, 0 for the loan period of the tier
+	LoanDays int
+	// Material only used in the library can not be lended
+	Lendable bool
+	// Renewals allowed regardless of tier, tierRenewals when the tier decides
+	MaxRenewals int
+	// Penalty per day late for items charged the default of their material
+	DayPenalty int
+}
+
+var materialPolicies = map[servicelib.MaterialType]MaterialPolicy{
+	servicelib.MaterialBook:      {LoanDays: 0, Lendable: true, MaxRenewals: tierRenewals, DayPenalty: 10},
+	servicelib.MaterialDVD:       {LoanDays: 7, Lendable: true, MaxRenewals: 1, DayPenalty: 20},
+	servicelib.MaterialMagazine:  {LoanDays: 7, Lendable: true, MaxRenewals: 0, DayPenalty: 5},
+	servicelib.MaterialReference: {LoanDays: 0, Lendable: false, MaxRenewals: 0, DayPenalty: 0},
+	servicelib.MaterialEquipment: {LoanDays: 3, Lendable: true, MaxRenewals: 0, DayPenalty: 50},
+	servicelib.MaterialEReader:   {LoanDays: 14, Lendable: true, MaxRenewals: 1, DayPenalty: 50},
+}
+
+// GetMaterialPolicy lending rules for the material type of book
+func GetMaterialPolicy(book *servicelib.Book) MaterialPolicy {
+	if policy, ok := materialPolicies[book.MaterialType]; ok {
+		return policy
+	}
+
+	// Items registered before material types were introduced
+	return materialPolicies[servicelib.MaterialBook]
+}
+
+func getMaterialType(book *servicelib.Book) servicelib.MaterialType {
+	if _, ok := materialPolicies[book.MaterialType]; ok {
+		return book.MaterialType
+	}
+	return servicelib.MaterialBook
+}
+
+// Material loan periods apply everywhere, branches may replace the loan period of the tier
+func getLoanDays(book *servicelib.Book, policy TierPolicy, branch BranchPolicy) int {
+	if loanDays := GetMaterialPolicy(book).LoanDays; loanDays > 0 {
+		return loanDays
+	}
+	if branch.LoanDays > 0 {
+		return branch.LoanDays
+	}
+	return policy.LoanDays
+}
+
+// The stricter of material and tier
+func getMaxRenewals(book *servicelib.Book, policy TierPolicy) int {
+	maxRenewals := GetMaterialPolicy(book).MaxRenewals
+	if maxRenewals == tierRenewals || maxRenewals > policy.MaxRenewals {
+		return policy.MaxRenewals
+	}
+	return maxRenewals
+}
+
+// A penalty of 0 makes returning late free
+func getDayPenalty(book *servicelib.Book) int {
+	if book.DayPenalty == servicelib.DefaultDayPenalty {
+		return GetMaterialPolicy(book).DayPenalty
+	}
+	return book.DayPenalty
+}
+
+// MaterialLendableRule rejects lending material only used in the library
+var MaterialLendableRule = Rule{
+	Name: RuleMaterialLendable,
+	Check: func(ctx *EligibilityContext) error {
+		if ctx.Book == nil || GetMaterialPolicy(ctx.Book).Lendable {
+			return nil
+		}
+		return lendingErrorf(CodeInLibraryOnly, 0, "Book %s is %s material for use in the library only", ctx.Book.ID, getMaterialType(ctx.Book))
+	},
+	Values: func(ctx *EligibilityContext) map[string]interface{} {
+		materialType := ""
+		if ctx.Book != nil {
+			materialType = string(getMaterialType(ctx.Book))
+		}
+		return map[string]interface{}{"materialType": materialType}
+	},
+}
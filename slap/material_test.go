@@ -0,0 +1,170 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaterialLoanPeriod(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		materialType servicelib.MaterialType
+		tier         servicelib.Tier
+		loanDays     int
+	}{
+		{"", servicelib.TierAdult, 7},
+		{servicelib.MaterialBook, servicelib.TierStaff, 28},
+		{servicelib.MaterialDVD, servicelib.TierStaff, 7},
+		{servicelib.MaterialMagazine, servicelib.TierChild, 7},
+		{servicelib.MaterialEquipment, servicelib.TierAdult, 3},
+		{servicelib.MaterialEReader, servicelib.TierAdult, 14},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, MaterialType: tt.materialType}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30, Tier: tt.tier}, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+		libraryService.On("SaveBook", book).Return(nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		assert.Nil(t, err)
+		assert.Equal(t, now.AddDate(0, 0, tt.loanDays), book.CurrentLend.LatestReturnDate, string(tt.materialType))
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestReferenceMaterialNotLendable(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID, MaterialType: servicelib.MaterialReference})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+
+	trace := &DecisionTrace{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithDecisionTrace(trace))
+	assert.Error(t, err)
+	assert.Equal(t, "Book 12345 is reference material for use in the library only", err.Error())
+	assert.Equal(t, "Bok 12345 kan bare brukes på biblioteket", Localize(err, "nb"))
+	assert.Equal(t, RuleMaterialLendable, trace.FirstFailure().Rule)
+	libraryService.AssertExpectations(t)
+}
+
+func TestMaterialDayPenalty(t *testing.T) {
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	lend := &servicelib.Lend{LatestReturnDate: now.AddDate(0, 0, -2)}
+
+	testCases := []struct {
+		book  *servicelib.Book
+		price int
+	}{
+		{&servicelib.Book{DayPenalty: servicelib.DefaultDayPenalty, CurrentLend: lend}, 20},
+		{&servicelib.Book{MaterialType: servicelib.MaterialDVD, DayPenalty: servicelib.DefaultDayPenalty, CurrentLend: lend}, 40},
+		{&servicelib.Book{MaterialType: servicelib.MaterialEquipment, DayPenalty: servicelib.DefaultDayPenalty, CurrentLend: lend}, 100},
+		{&servicelib.Book{MaterialType: servicelib.MaterialEquipment, DayPenalty: 30, CurrentLend: lend}, 60},
+		// Free to return late
+		{&servicelib.Book{MaterialType: servicelib.MaterialEquipment, CurrentLend: lend}, 0},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.price, calculatePriceForLateReturn(tt.book, now), string(tt.book.MaterialType))
+	}
+}
+
+func TestMaterialRenewals(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		materialType servicelib.MaterialType
+		renewals     int
+		expectedErr  string
+	}{
+		{servicelib.MaterialBook, 1, ""},
+		{servicelib.MaterialDVD, 1, "Book 12345 is renewed 1 times, 1 is the limit"},
+		{servicelib.MaterialEquipment, 0, "Book 12345 can not be renewed"},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, MaterialType: tt.materialType, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: tt.renewals}}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+		if tt.expectedErr == "" {
+			libraryService.On("SaveBook", book).Return(nil)
+		}
+
+		err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		if tt.expectedErr == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, tt.expectedErr, err.Error())
+		}
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestDeskRenewalLimitedByMaterial(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, MaterialType: servicelib.MaterialMagazine, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: time.Now().AddDate(0, 0, 1)}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, CodeNotRenewable, findLendingError(err).Code)
+	assert.Equal(t, "Book 12345 can not be renewed", Localize(err, "en"))
+	libraryService.AssertExpectations(t)
+}
+
+func TestSelfServiceRenewalAtKioskLimitedByTier(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		actor       servicelib.Actor
+		lend        *servicelib.Lend
+		holds       []*servicelib.Hold
+		expectedErr string
+	}{
+		{kiosk, &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: 2}, nil, "Book 12345 is renewed 2 times, 2 is the limit"},
+		{kiosk, &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}, []*servicelib.Hold{{CustomerID: 654321}}, "Book 12345 is on hold for another customer"},
+		// Staff may go beyond the tier at the desk
+		{librarian, &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: 2}, []*servicelib.Hold{{CustomerID: 654321}}, ""},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, CurrentLend: tt.lend, Holds: tt.holds}
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+		if tt.expectedErr == "" {
+			libraryService.On("SaveBook", book).Return(nil)
+		}
+
+		err := LendBook(tt.actor, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		if tt.expectedErr == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, tt.expectedErr, err.Error())
+		}
+		libraryService.AssertExpectations(t)
+	}
+}
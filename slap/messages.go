@@ -0,0 +1,270 @@
+package tldr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eirikbell/slap/notification"
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// ErrorCode identifies a lending failure the customer can be told about
+type ErrorCode string
+
+// Lending failures with customer facing messages
+const (
+	CodeUnknown                  ErrorCode = "unknown"
+	CodePermissionDenied         ErrorCode = "permission-denied"
+	CodeBookNotFound             ErrorCode = "book-not-found"
+	CodeBookLended               ErrorCode = "book-lended"
+	CodeCustomerNotFound         ErrorCode = "customer-not-found"
+	CodeGuardianNotFound         ErrorCode = "guardian-not-found"
+	CodeGuardianLocked           ErrorCode = "guardian-locked"
+	CodeAccountLocked            ErrorCode = "account-locked"
+	CodeAccountLockedUnpaidFines ErrorCode = "account-locked-unpaid-fines"
+	CodeAccountLockedOverdue     ErrorCode = "account-locked-overdue"
+	CodeLendLimitReached         ErrorCode = "lend-limit-reached"
+	CodeRenewLimitReached        ErrorCode = "renew-limit-reached"
+	CodeTooYoungToPay            ErrorCode = "too-young-to-pay"
+	CodeDebtLimitReached         ErrorCode = "debt-limit-reached"
+	CodePaymentFailed            ErrorCode = "payment-failed"
+	CodeLendFailed               ErrorCode = "lend-failed"
+	CodeRenewalFailed            ErrorCode = "renewal-failed"
+	CodeBookNotLendedToCustomer  ErrorCode = "book-not-lended-to-customer"
+	CodeOverdueRenewal           ErrorCode = "overdue-renewal"
+	CodeBookOnHold               ErrorCode = "book-on-hold"
+	CodeRenewalLimitReached      ErrorCode = "renewal-limit-reached"
+	CodeNotRenewable             ErrorCode = "not-renewable"
+	CodeOverdueBlock             ErrorCode = "overdue-block"
+	CodeAgeRestricted            ErrorCode = "age-restricted"
+	CodeInLibraryOnly            ErrorCode = "in-library-only"
+	CodeBookInTransit            ErrorCode = "book-in-transit"
+)
+
+// LendingError failure with a code, rendered in the customer's language with Localize
+type LendingError struct {
+	Code ErrorCode
+	// Selects the plural form of the message
+	Count int
+	// Values the message is formatted with, translations refer to them by index
+	Args []interface{}
+
+	message string
+	cause   error
+}
+
+func (e *LendingError) Error() string {
+	if e.cause != nil {
+		return e.message + ": " + e.cause.Error()
+	}
+	return e.message
+}
+
+// Cause underlying error, if any
+func (e *LendingError) Cause() error {
+	return e.cause
+}
+
+func lendingErrorf(code ErrorCode, count int, format string, args ...interface{}) *LendingError {
+	return &LendingError{Code: code, Count: count, Args: args, message: fmt.Sprintf(format, args...)}
+}
+
+func wrapLendingError(err error, code ErrorCode, message string) *LendingError {
+	return &LendingError{Code: code, message: message, cause: err}
+}
+
+// PluralForm grammatical number a message is written in
+type PluralForm int
+
+// Plural forms used by the supported languages
+const (
+	PluralOne PluralForm = iota
+	PluralOther
+)
+
+// PluralRule picks the plural form for a count
+type PluralRule func(n int) PluralForm
+
+func oneOrOther(n int) PluralForm {
+	if n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// PluralRules per language, languages without a rule use the English one
+var PluralRules = map[string]PluralRule{
+	"en": oneOrOther,
+	"nb": oneOrOther,
+}
+
+// Translation message in one language, One is optional when the text does not depend on the count
+type Translation struct {
+	One   string
+	Other string
+}
+
+// MessageCatalog translations per language and error code
+type MessageCatalog map[string]map[ErrorCode]Translation
+
+// DefaultMessages the library's own English and Norwegian messages
+var DefaultMessages = MessageCatalog{
+	"en": {
+		CodeUnknown:                  {Other: "Something went wrong, please contact the library staff"},
+		CodePermissionDenied:         {Other: "You are not permitted to do this"},
+		CodeBookNotFound:             {Other: "The book was not found"},
+		CodeBookLended:               {Other: "The book is already lent to someone else"},
+		CodeCustomerNotFound:         {Other: "Your library card was not found"},
+		CodeGuardianNotFound:         {Other: "Your guardian was not found, please contact the library staff"},
+		CodeGuardianLocked:           {Other: "Your guardian's account is locked, please contact the library staff"},
+		CodeAccountLocked:            {Other: "Your account is locked, please contact the library staff"},
+		CodeAccountLockedUnpaidFines: {Other: "Your account is locked because of unpaid fines"},
+		CodeAccountLockedOverdue:     {Other: "Your account is locked because of overdue books"},
+		CodeLendLimitReached: {
+			One:   "You already have %[1]d book on loan, the limit is %[2]d",
+			Other: "You already have %[1]d books on loan, the limit is %[2]d",
+		},
+		CodeRenewLimitReached: {
+			One:   "You cannot renew with %[2]d book on loan, the limit is %[1]d",
+			Other: "You cannot renew with %[2]d books on loan, the limit is %[1]d",
+		},
+		CodeTooYoungToPay: {
+			One:   "Your overdue book must be settled by a guardian, we cannot collect fines from customers younger than 13",
+			Other: "Your %[1]d overdue books must be settled by a guardian, we cannot collect fines from customers younger than 13",
+		},
+		CodeDebtLimitReached:        {Other: "You owe %[2]d, the limit is %[3]d. Please pay before lending more books"},
+		CodePaymentFailed:           {Other: "Payment failed, please try again or contact the library staff"},
+		CodeLendFailed:              {Other: "The lend could not be registered, please contact the library staff"},
+		CodeRenewalFailed:           {Other: "The renewal could not be registered, please contact the library staff"},
+		CodeBookNotLendedToCustomer: {Other: "You have not borrowed book %[1]s"},
+		CodeOverdueRenewal:          {Other: "Book %[1]s is overdue and must be renewed at the desk"},
+		CodeBookOnHold:              {Other: "Book %[1]s is reserved by another customer and cannot be renewed"},
+		CodeRenewalLimitReached: {
+			One:   "Book %[1]s can only be renewed once",
+			Other: "Book %[1]s can only be renewed %[3]d times",
+		},
+		CodeNotRenewable: {Other: "Book %[1]s can not be renewed"},
+		CodeOverdueBlock: {
+			One:   "Please return your overdue book before borrowing more",
+			Other: "Please return your %[1]d overdue books before borrowing more",
+		},
+		CodeAgeRestricted: {Other: "Book %[1]s is for customers aged %[2]d or older, a guardian can give consent at the desk"},
+		CodeInLibraryOnly: {Other: "Book %[1]s can only be used in the library"},
+		CodeBookInTransit: {Other: "Book %[1]s is on its way to another branch and can not be lent yet"},
+	},
+	"nb": {
+		CodeUnknown:                  {Other: "Noe gikk galt, ta kontakt med bibliotekets ansatte"},
+		CodePermissionDenied:         {Other: "Du har ikke tilgang til å gjøre dette"},
+		CodeBookNotFound:             {Other: "Fant ikke boka"},
+		CodeBookLended:               {Other: "Boka er allerede lånt ut til en annen"},
+		CodeCustomerNotFound:         {Other: "Fant ikke lånekortet ditt"},
+		CodeGuardianNotFound:         {Other: "Fant ikke din foresatte, ta kontakt med bibliotekets ansatte"},
+		CodeGuardianLocked:           {Other: "Kontoen til din foresatte er sperret, ta kontakt med bibliotekets ansatte"},
+		CodeAccountLocked:            {Other: "Kontoen din er sperret, ta kontakt med bibliotekets ansatte"},
+		CodeAccountLockedUnpaidFines: {Other: "Kontoen din er sperret på grunn av ubetalte gebyrer"},
+		CodeAccountLockedOverdue:     {Other: "Kontoen din er sperret på grunn av bøker som ikke er levert"},
+		CodeLendLimitReached: {
+			One:   "Du har allerede %[1]d bok på lån, grensen er %[2]d",
+			Other: "Du har allerede %[1]d bøker på lån, grensen er %[2]d",
+		},
+		CodeRenewLimitReached: {
+			One:   "Du kan ikke fornye med %[2]d bok på lån, grensen er %[1]d",
+			Other: "Du kan ikke fornye med %[2]d bøker på lån, grensen er %[1]d",
+		},
+		CodeTooYoungToPay: {
+			One:   "Den forsinkede boka di må gjøres opp av en foresatt, vi kan ikke kreve gebyr fra kunder under 13 år",
+			Other: "De %[1]d forsinkede bøkene dine må gjøres opp av en foresatt, vi kan ikke kreve gebyr fra kunder under 13 år",
+		},
+		CodeDebtLimitReached:        {Other: "Du skylder %[2]d, grensen er %[3]d. Betal før du låner flere bøker"},
+		CodePaymentFailed:           {Other: "Betalingen feilet, prøv igjen eller ta kontakt med bibliotekets ansatte"},
+		CodeLendFailed:              {Other: "Utlånet kunne ikke registreres, ta kontakt med bibliotekets ansatte"},
+		CodeRenewalFailed:           {Other: "Fornyelsen kunne ikke registreres, ta kontakt med bibliotekets ansatte"},
+		CodeBookNotLendedToCustomer: {Other: "Du har ikke lånt bok %[1]s"},
+		CodeOverdueRenewal:          {Other: "Bok %[1]s er ikke levert i tide og må fornyes i skranken"},
+		CodeBookOnHold:              {Other: "Bok %[1]s er reservert av en annen kunde og kan ikke fornyes"},
+		CodeRenewalLimitReached: {
+			One:   "Bok %[1]s kan bare fornyes én gang",
+			Other: "Bok %[1]s kan bare fornyes %[3]d ganger",
+		},
+		CodeNotRenewable: {Other: "Bok %[1]s kan ikke fornyes"},
+		CodeOverdueBlock: {
+			One:   "Lever den forsinkede boka di før du låner flere",
+			Other: "Lever de %[1]d forsinkede bøkene dine før du låner flere",
+		},
+		CodeAgeRestricted: {Other: "Bok %[1]s har aldersgrense %[2]d år, en foresatt kan gi samtykke i skranken"},
+		CodeInLibraryOnly: {Other: "Bok %[1]s kan bare brukes på biblioteket"},
+		CodeBookInTransit: {Other: "Bok %[1]s er på vei til en annen filial og kan ikke lånes ennå"},
+	},
+}
+
+// Localize renders err as a message to the customer in language, using the default messages
+func Localize(err error, language string) string {
+	return DefaultMessages.Localize(err, language)
+}
+
+// Localize renders err as a message to the customer in language, falling back to English
+func (c MessageCatalog) Localize(err error, language string) string {
+	lendingErr := findLendingError(err)
+
+	translation, language := c.lookup(lendingErr.Code, language)
+	message := translation.Other
+	if translation.One != "" && getPluralRule(language)(lendingErr.Count) == PluralOne {
+		message = translation.One
+	}
+
+	// Messages without values would get the arguments appended
+	if !strings.Contains(message, "%") {
+		return message
+	}
+	return fmt.Sprintf(message, lendingErr.Args...)
+}
+
+func (c MessageCatalog) lookup(code ErrorCode, language string) (Translation, string) {
+	if translation, ok := c[language][code]; ok {
+		return translation, language
+	}
+
+	if translation, ok := c[notification.DefaultLanguage][code]; ok {
+		return translation, notification.DefaultLanguage
+	}
+	return c[notification.DefaultLanguage][CodeUnknown], notification.DefaultLanguage
+}
+
+func getPluralRule(language string) PluralRule {
+	if rule, ok := PluralRules[language]; ok {
+		return rule
+	}
+	return PluralRules[notification.DefaultLanguage]
+}
+
+type causer interface {
+	Cause() error
+}
+
+// Nearest lending error in the chain of causes, system errors are never shown to customers
+func findLendingError(err error) *LendingError {
+	for err != nil {
+		switch e := err.(type) {
+		case *LendingError:
+			return e
+		case *PermissionDeniedError:
+			return &LendingError{Code: CodePermissionDenied}
+		case causer:
+			err = e.Cause()
+		default:
+			err = nil
+		}
+	}
+	return &LendingError{Code: CodeUnknown}
+}
+
+func getAccountLockedCode(reason servicelib.LockReason) ErrorCode {
+	switch reason {
+	case servicelib.LockReasonUnpaidFines:
+		return CodeAccountLockedUnpaidFines
+	case servicelib.LockReasonOverdue:
+		return CodeAccountLockedOverdue
+	default:
+		return CodeAccountLocked
+	}
+}
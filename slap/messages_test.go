@@ -0,0 +1,71 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalize(t *testing.T) {
+	testCases := []struct {
+		err             error
+		language        string
+		expectedMessage string
+	}{
+		{&LendingError{Code: CodeLendLimitReached, Count: 3, Args: []interface{}{3, 3}}, "en", "You already have 3 books on loan, the limit is 3"},
+		{&LendingError{Code: CodeLendLimitReached, Count: 1, Args: []interface{}{1, 1}}, "en", "You already have 1 book on loan, the limit is 1"},
+		{&LendingError{Code: CodeLendLimitReached, Count: 3, Args: []interface{}{3, 3}}, "nb", "Du har allerede 3 bøker på lån, grensen er 3"},
+		{&LendingError{Code: CodeLendLimitReached, Count: 1, Args: []interface{}{1, 1}}, "nb", "Du har allerede 1 bok på lån, grensen er 1"},
+		{&LendingError{Code: CodeRenewLimitReached, Count: 4, Args: []interface{}{3, 4}}, "en", "You cannot renew with 4 books on loan, the limit is 3"},
+		{&LendingError{Code: CodeTooYoungToPay, Count: 1, Args: []interface{}{1}}, "en", "Your overdue book must be settled by a guardian, we cannot collect fines from customers younger than 13"},
+		{&LendingError{Code: CodeTooYoungToPay, Count: 2, Args: []interface{}{2}}, "nb", "De 2 forsinkede bøkene dine må gjøres opp av en foresatt, vi kan ikke kreve gebyr fra kunder under 13 år"},
+		{&LendingError{Code: CodeRenewalLimitReached, Count: 1, Args: []interface{}{"12345", 1, 1}}, "nb", "Bok 12345 kan bare fornyes én gang"},
+		{&LendingError{Code: CodeDebtLimitReached, Count: 0, Args: []interface{}{1, 120, 100}}, "en", "You owe 120, the limit is 100. Please pay before lending more books"},
+		{&LendingError{Code: CodeBookNotFound}, "de", "The book was not found"},
+		{wrapLendingError(fmt.Errorf("DB error"), CodeLendFailed, "Lend failed"), "nb", "Utlånet kunne ikke registreres, ta kontakt med bibliotekets ansatte"},
+		{errors.Wrap(&LendingError{Code: CodeBookOnHold, Count: 0, Args: []interface{}{"12345"}}, "Renewing failed"), "en", "Book 12345 is reserved by another customer and cannot be renewed"},
+		{&PermissionDeniedError{Actor: kiosk, Permission: PermissionWaive}, "nb", "Du har ikke tilgang til å gjøre dette"},
+		{fmt.Errorf("DB error"), "en", "Something went wrong, please contact the library staff"},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expectedMessage, Localize(tt.err, tt.language))
+	}
+}
+
+func TestLendingErrorKeepsMessage(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -31)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book, nonReturnedBook}, nil)
+
+	// Late fees are not settled when renewing without the book in hand
+	err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Error(t, err)
+	assert.Equal(t, "Customer account is locked due to overdue books", err.Error())
+	assert.Equal(t, CodeAccountLockedOverdue, err.(*LendingError).Code)
+	assert.Equal(t, "Kontoen din er sperret på grunn av bøker som ikke er levert", Localize(err, "nb"))
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestCustomCatalog(t *testing.T) {
+	catalog := MessageCatalog{
+		"en": {CodeUnknown: {Other: "Ask at the desk"}},
+		"se": {CodeBookNotFound: {Other: "Girji ii gávdnon"}},
+	}
+
+	assert.Equal(t, "Girji ii gávdnon", catalog.Localize(lendingErrorf(CodeBookNotFound, 0, "Book not found"), "se"))
+	assert.Equal(t, "Ask at the desk", catalog.Localize(lendingErrorf(CodeLendFailed, 0, "Lend failed"), "se"))
+}
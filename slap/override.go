@@ -0,0 +1,163 @@
+package tldr
+
+import (
+	"fmt"
+
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// Override exception from the lending rules approved by staff
+type Override struct {
+	ApprovedBy  *servicelib.Actor
+	Reason      servicelib.OverrideReason
+	WaiveFees   bool
+	ExceedLimit bool
+}
+
+var overrideReasons = map[servicelib.OverrideReason]bool{
+	servicelib.OverrideReasonHardship:     true,
+	servicelib.OverrideReasonLibraryError: true,
+	servicelib.OverrideReasonSpecialNeeds: true,
+	servicelib.OverrideReasonCourseWork:   true,
+}
+
+// WaiveBalance forgives all or part of what customer owes on the ledger
+func WaiveBalance(actor servicelib.Actor, customerID int, amount int, reason servicelib.OverrideReason, ledger servicelib.Ledger, auditLog servicelib.AuditLog, options ...LendOption) error {
+	override := Override{ApprovedBy: &actor, Reason: reason, WaiveFees: true}
+	tx := newTransaction(options, performedBy(actor), WithOverride(override), WithAuditLog(auditLog))
+	if err := validateOverride(tx); err != nil {
+		return err
+	}
+
+	balance, err := GetBalance(customerID, ledger)
+	if err != nil {
+		return err
+	}
+
+	if amount <= 0 || amount > balance {
+		return fmt.Errorf("Cannot waive %d, customer owes %d", amount, balance)
+	}
+
+	if err := postLedgerEntry(ledger, createLedgerEntry(customerID, servicelib.LedgerEntryWaiver, amount, nil, tx.now)); err != nil {
+		return err
+	}
+
+	// Must manually register later, the waiver is already posted
+	if err := recordOverride(tx, servicelib.AuditActionWaiveFees, customerID, nil, amount, "Waived ledger balance"); err != nil {
+		logDecision(tx, logging.LevelError, "Recording waiver failed", logging.CustomerID(customerID), logging.Int("amount", amount), logging.Error(err))
+		return fmt.Errorf("Recording waiver failed, manually register waiver of %d for customer %d", amount, customerID)
+	}
+	return nil
+}
+
+func validateOverride(tx *transaction) error {
+	if tx.override == nil {
+		return nil
+	}
+
+	if tx.override.ApprovedBy == nil {
+		return fmt.Errorf("Override must be approved by staff")
+	}
+
+	if err := authorizeOverride(tx.override); err != nil {
+		return err
+	}
+
+	if !overrideReasons[tx.override.Reason] {
+		return fmt.Errorf("Override reason %q is not valid", tx.override.Reason)
+	}
+
+	// Overrides are never made without a trail
+	if tx.auditLog == nil {
+		return fmt.Errorf("Override cannot be recorded, no audit log")
+	}
+	return nil
+}
+
+func authorizeOverride(override *Override) error {
+	if override.WaiveFees {
+		if err := authorize(*override.ApprovedBy, PermissionWaive); err != nil {
+			return err
+		}
+	}
+
+	if override.ExceedLimit {
+		return authorize(*override.ApprovedBy, PermissionExceedLimit)
+	}
+	return nil
+}
+
+func overrideLendingLimit(tx *transaction, customer *servicelib.Customer, limitErr error) error {
+	if tx.override == nil || !tx.override.ExceedLimit {
+		return limitErr
+	}
+
+	return recordOverride(tx, servicelib.AuditActionExceedLimit, customer.ID, nil, 0, limitErr.Error())
+}
+
+func isFeeWaived(tx *transaction) bool {
+	return tx.override != nil && tx.override.WaiveFees
+}
+
+func waiveLateReturns(tx *transaction, customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, libraryService servicelib.LibraryService) error {
+	priceToWaive, err := waiveLateFees(tx, customer, notReturnedBookLends)
+	if err != nil || priceToWaive == 0 {
+		return err
+	}
+
+	return renewBookLends(tx, customer, notReturnedBookLends, libraryService)
+}
+
+func waiveLateFees(tx *transaction, customer *servicelib.Customer, books []*servicelib.Book) (int, error) {
+	priceToWaive := calculateTotalPriceForLateReturn(customer, books, tx.now)
+	if priceToWaive == 0 {
+		return 0, nil
+	}
+
+	if err := postWaivedLateFees(tx, customer, books, priceToWaive); err != nil {
+		return 0, err
+	}
+
+	// Recorded once posted, the audit trail only shows waivers that happened
+	if err := recordOverride(tx, servicelib.AuditActionWaiveFees, customer.ID, books, priceToWaive, "Waived fees for late return"); err != nil {
+		return 0, err
+	}
+	return priceToWaive, nil
+}
+
+func postWaivedLateFees(tx *transaction, customer *servicelib.Customer, notReturnedBookLends []*servicelib.Book, priceToWaive int) error {
+	if tx.ledger == nil {
+		return nil
+	}
+
+	// Charge and waiver both posted to keep the fee visible in the ledger
+	charge := createLedgerEntry(customer.ID, servicelib.LedgerEntryCharge, priceToWaive, notReturnedBookLends, tx.now)
+	if err := postLedgerEntry(tx.ledger, charge); err != nil {
+		return err
+	}
+
+	waiver := createLedgerEntry(customer.ID, servicelib.LedgerEntryWaiver, priceToWaive, notReturnedBookLends, tx.now)
+	return postLedgerEntry(tx.ledger, waiver)
+}
+
+func recordOverride(tx *transaction, action servicelib.AuditAction, customerID int, books []*servicelib.Book, amount int, details string) error {
+	entry := &servicelib.AuditEntry{
+		Action:      action,
+		Time:        tx.now,
+		CustomerID:  customerID,
+		BookIDs:     getBookIDs(books),
+		Amount:      amount,
+		PerformedBy: tx.actor.ID,
+		ApprovedBy:  tx.override.ApprovedBy.ID,
+		Reason:      tx.override.Reason,
+		Details:     details,
+		Branch:      tx.branch,
+	}
+
+	if err := tx.auditLog.RecordAudit(entry); err != nil {
+		return errors.Wrap(err, "Recording override failed")
+	}
+	return nil
+}
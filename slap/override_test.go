@@ -0,0 +1,219 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOverrideNotValid(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	testCases := []struct {
+		override    Override
+		auditLog    servicelib.AuditLog
+		expectedErr string
+	}{
+		{Override{Reason: servicelib.OverrideReasonHardship, WaiveFees: true}, new(mocks.AuditLog), "Override must be approved by staff"},
+		{Override{ApprovedBy: &librarian, Reason: servicelib.OverrideReasonHardship, WaiveFees: true}, new(mocks.AuditLog), "Permission denied, staff ola is not permitted to waive"},
+		{Override{ApprovedBy: &supervisor, Reason: "because", WaiveFees: true}, new(mocks.AuditLog), "Override reason \"because\" is not valid"},
+		{Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonHardship, WaiveFees: true}, nil, "Override cannot be recorded, no audit log"},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithOverride(tt.override), WithAuditLog(tt.auditLog))
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestOverrideWaivesFees(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		age int
+	}{
+		{10},
+		{30},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+		nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+		customer := &servicelib.Customer{ID: customerID, Age: tt.age}
+		expectedAudit := &servicelib.AuditEntry{
+			Action:      servicelib.AuditActionWaiveFees,
+			Time:        now,
+			CustomerID:  customerID,
+			BookIDs:     []string{nonReturnedBook.ID},
+			Amount:      calculateTotalPriceForLateReturn(customer, []*servicelib.Book{nonReturnedBook}, now),
+			PerformedBy: librarian.ID,
+			ApprovedBy:  supervisor.ID,
+			Reason:      servicelib.OverrideReasonHardship,
+			Details:     "Waived fees for late return",
+			Branch:      branch,
+		}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+		libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+		libraryService.On("SaveBook", book).Return(nil)
+		auditLog := new(mocks.AuditLog)
+		auditLog.On("RecordAudit", expectedAudit).Return(nil)
+		auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: now, CustomerID: customerID, BookIDs: []string{bookID}, PerformedBy: librarian.ID, Tier: servicelib.TierAdult, Branch: branch}).Return(nil)
+
+		override := Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonHardship, WaiveFees: true}
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithOverride(override), WithAuditLog(auditLog))
+		assert.Nil(t, err)
+		assert.Equal(t, now.AddDate(0, 0, 7), nonReturnedBook.CurrentLend.LatestReturnDate)
+
+		libraryService.AssertExpectations(t)
+		auditLog.AssertExpectations(t)
+	}
+}
+
+func TestOverrideWaivesFeesPostedOnLedger(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{}, nil)
+	ledger.On("AddLedgerEntry", &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryCharge, Amount: 20, Time: now, BookIDs: []string{nonReturnedBook.ID}}).Return(nil)
+	ledger.On("AddLedgerEntry", &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryWaiver, Amount: 20, Time: now, BookIDs: []string{nonReturnedBook.ID}}).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionWaiveFees, Time: now, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 20, PerformedBy: librarian.ID, ApprovedBy: supervisor.ID, Reason: servicelib.OverrideReasonLibraryError, Details: "Waived fees for late return", Branch: branch}).Return(nil)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: now, CustomerID: customerID, BookIDs: []string{bookID}, PerformedBy: librarian.ID, Tier: servicelib.TierAdult, Branch: branch}).Return(nil)
+
+	override := Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonLibraryError, WaiveFees: true}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLedger(ledger), WithOverride(override), WithAuditLog(auditLog))
+	assert.Nil(t, err)
+
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestOverrideExceedsLendingLimit(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	customerLends := []*servicelib.Book{&servicelib.Book{}, &servicelib.Book{}, &servicelib.Book{}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return(customerLends, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionExceedLimit, Time: now, CustomerID: customerID, BookIDs: []string{}, PerformedBy: librarian.ID, ApprovedBy: supervisor.ID, Reason: servicelib.OverrideReasonCourseWork, Details: "Customer already has 3 lended books, 3 is the limit", Branch: branch}).Return(nil)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionLend, Time: now, CustomerID: customerID, BookIDs: []string{bookID}, PerformedBy: librarian.ID, Tier: servicelib.TierAdult, Branch: branch}).Return(nil)
+
+	override := Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonCourseWork, ExceedLimit: true}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithOverride(override), WithAuditLog(auditLog))
+	assert.Nil(t, err)
+
+	libraryService.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestOverrideNotRecorded(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	expectedErr := fmt.Errorf("DB error")
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	customerLends := []*servicelib.Book{&servicelib.Book{}, &servicelib.Book{}, &servicelib.Book{}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return(customerLends, nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionExceedLimit, Time: time.Time{}, CustomerID: customerID, BookIDs: []string{}, PerformedBy: librarian.ID, ApprovedBy: supervisor.ID, Reason: servicelib.OverrideReasonCourseWork, Details: "Customer already has 3 lended books, 3 is the limit", Branch: branch}).Return(expectedErr)
+
+	override := Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonCourseWork, ExceedLimit: true}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return time.Time{} }), WithOverride(override), WithAuditLog(auditLog))
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("Recording override failed: %s", expectedErr.Error()), err.Error())
+
+	libraryService.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestWaiveBalance(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 50}}, nil)
+	ledger.On("AddLedgerEntry", &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryWaiver, Amount: 50, Time: now, BookIDs: []string{}}).Return(nil)
+	auditLog := new(mocks.AuditLog)
+	auditLog.On("RecordAudit", &servicelib.AuditEntry{Action: servicelib.AuditActionWaiveFees, Time: now, CustomerID: customerID, BookIDs: []string{}, Amount: 50, PerformedBy: supervisor.ID, ApprovedBy: supervisor.ID, Reason: servicelib.OverrideReasonHardship, Details: "Waived ledger balance"}).Return(nil)
+
+	err := WaiveBalance(supervisor, customerID, 50, servicelib.OverrideReasonHardship, ledger, auditLog, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+
+	ledger.AssertExpectations(t)
+	auditLog.AssertExpectations(t)
+}
+
+func TestWaiveBalanceFails(t *testing.T) {
+	customerID := 123456
+	expectedErr := fmt.Errorf("DB error")
+
+	testCases := []struct {
+		postErr     error
+		auditErr    error
+		expectedErr string
+	}{
+		// Nothing recorded for a waiver that did not happen
+		{expectedErr, nil, "Posting waiver failed: DB error"},
+		{nil, expectedErr, "Recording waiver failed, manually register waiver of 50 for customer 123456"},
+	}
+
+	for _, tt := range testCases {
+		ledger := new(mocks.Ledger)
+		ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{&servicelib.LedgerEntry{Type: servicelib.LedgerEntryCharge, Amount: 50}}, nil)
+		ledger.On("AddLedgerEntry", mock.AnythingOfType("*servicelib.LedgerEntry")).Return(tt.postErr)
+		auditLog := new(mocks.AuditLog)
+		if tt.postErr == nil {
+			auditLog.On("RecordAudit", mock.AnythingOfType("*servicelib.AuditEntry")).Return(tt.auditErr)
+		}
+
+		err := WaiveBalance(supervisor, customerID, 50, servicelib.OverrideReasonHardship, ledger, auditLog)
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		ledger.AssertExpectations(t)
+		auditLog.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,196 @@
+package tldr
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/servicelib"
+)
+
+// PlannedActionType change a lend would make once performed
+type PlannedActionType string
+
+// Changes planned by QuoteLend
+const (
+	PlannedCollectPayment  PlannedActionType = "collect-payment"
+	PlannedPostLedgerEntry PlannedActionType = "post-ledger-entry"
+	PlannedSaveBook        PlannedActionType = "save-book"
+	PlannedSaveCustomer    PlannedActionType = "save-customer"
+	PlannedRecordOverride  PlannedActionType = "record-override"
+)
+
+// PlannedAction single change a lend would make
+type PlannedAction struct {
+	Type       PlannedActionType
+	CustomerID int
+	BookIDs    []string
+	Amount     int
+	// Latest return date of a saved book
+	LatestReturnDate time.Time
+	// Ledger entry type, lock reason or audit action, depending on the type
+	Detail string
+}
+
+// Quote what lending a book would do and cost, without doing it
+type Quote struct {
+	BookID     string
+	CustomerID int
+	IsRenewal  bool
+	// Latest return date of the book once lended or renewed
+	LatestReturnDate time.Time
+	// Late fees paid now or added to the debt, after waivers
+	TotalPrice int
+	// Changes in the order they would be made
+	Actions []*PlannedAction
+}
+
+// QuoteLend runs every rule of LendBook without collecting payment or saving anything, returns why the lend would fail otherwise
+func QuoteLend(actor servicelib.Actor, branch string, bookID string, customerID int, libraryService servicelib.LibraryService, options ...LendOption) (*Quote, error) {
+	tx := newTransaction(options, performedBy(actor), atBranch(branch))
+	quote := &Quote{BookID: bookID, CustomerID: customerID}
+	planner := newQuotePlanner(tx, quote, libraryService)
+
+	startTrace(tx, "QuoteLend", bookID, customerID)
+	err := lendOrRenew(tx, bookID, customerID, planner)
+	endTrace(tx, err)
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// Stands in for every store the transaction writes to, reads still go to the real stores
+type quotePlanner struct {
+	quote          *Quote
+	libraryService servicelib.LibraryService
+	ledger         servicelib.Ledger
+	// Books as they were before the transaction changed them
+	originalBooks map[string]*servicelib.Book
+}
+
+func newQuotePlanner(tx *transaction, quote *Quote, libraryService servicelib.LibraryService) *quotePlanner {
+	planner := &quotePlanner{quote: quote, libraryService: libraryService, ledger: tx.ledger, originalBooks: map[string]*servicelib.Book{}}
+
+	// Nothing is sent or published for a lend that does not happen
+	tx.publisher = nil
+	tx.sender = nil
+	tx.quote = true
+	// Only replaced when set, a missing store changes which rules apply
+	if tx.ledger != nil {
+		tx.ledger = planner
+	}
+	if tx.customerStore != nil {
+		tx.customerStore = planner
+	}
+	if tx.auditLog != nil {
+		tx.auditLog = planner
+	}
+	return planner
+}
+
+func (p *quotePlanner) plan(action *PlannedAction) {
+	p.quote.Actions = append(p.quote.Actions, action)
+}
+
+// Rules change books and customers in place, copies keep the originals untouched
+func (p *quotePlanner) GetBook(bookID string) *servicelib.Book {
+	return p.copyBook(p.libraryService.GetBook(bookID))
+}
+
+func (p *quotePlanner) GetOldDbBooks() []*servicelib.Book {
+	return p.copyBooks(p.libraryService.GetOldDbBooks())
+}
+
+func (p *quotePlanner) GetCustomer(customerID int) (*servicelib.Customer, error) {
+	customer, err := p.libraryService.GetCustomer(customerID)
+	if err != nil || customer == nil {
+		return customer, err
+	}
+
+	c := *customer
+	return &c, nil
+}
+
+func (p *quotePlanner) GetLendsForCustomer(customerID int) ([]*servicelib.Book, error) {
+	books, err := p.libraryService.GetLendsForCustomer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	return p.copyBooks(books), nil
+}
+
+func (p *quotePlanner) CollectPayment(customerID int, amount int) error {
+	p.plan(&PlannedAction{Type: PlannedCollectPayment, CustomerID: customerID, Amount: amount})
+	p.quote.TotalPrice += amount
+	return nil
+}
+
+func (p *quotePlanner) SaveBook(book *servicelib.Book) error {
+	action := &PlannedAction{Type: PlannedSaveBook, BookIDs: []string{book.ID}}
+	if book.CurrentLend != nil {
+		action.CustomerID = book.CurrentLend.CustomerID
+		action.LatestReturnDate = book.CurrentLend.LatestReturnDate
+	}
+	p.plan(action)
+
+	if book.ID == p.quote.BookID {
+		original := p.originalBooks[book.ID]
+		p.quote.IsRenewal = original != nil && original.CurrentLend != nil
+		p.quote.LatestReturnDate = action.LatestReturnDate
+	}
+	return nil
+}
+
+func (p *quotePlanner) GetLedgerEntries(customerID int) ([]*servicelib.LedgerEntry, error) {
+	return p.ledger.GetLedgerEntries(customerID)
+}
+
+func (p *quotePlanner) AddLedgerEntry(entry *servicelib.LedgerEntry) error {
+	p.plan(&PlannedAction{Type: PlannedPostLedgerEntry, CustomerID: entry.CustomerID, BookIDs: entry.BookIDs, Amount: entry.Amount, Detail: string(entry.Type)})
+
+	switch entry.Type {
+	case servicelib.LedgerEntryCharge:
+		p.quote.TotalPrice += entry.Amount
+	case servicelib.LedgerEntryWaiver:
+		p.quote.TotalPrice -= entry.Amount
+	}
+	return nil
+}
+
+func (p *quotePlanner) SaveCustomer(customer *servicelib.Customer) error {
+	p.plan(&PlannedAction{Type: PlannedSaveCustomer, CustomerID: customer.ID, Detail: string(customer.LockReason)})
+	return nil
+}
+
+// Circulation is only statistics, overrides are planned since they need approval
+func (p *quotePlanner) RecordAudit(entry *servicelib.AuditEntry) error {
+	if entry.Action != servicelib.AuditActionWaiveFees && entry.Action != servicelib.AuditActionExceedLimit {
+		return nil
+	}
+
+	p.plan(&PlannedAction{Type: PlannedRecordOverride, CustomerID: entry.CustomerID, BookIDs: entry.BookIDs, Amount: entry.Amount, Detail: string(entry.Action)})
+	return nil
+}
+
+func (p *quotePlanner) copyBooks(books []*servicelib.Book) []*servicelib.Book {
+	copies := make([]*servicelib.Book, len(books))
+	for i, book := range books {
+		copies[i] = p.copyBook(book)
+	}
+	return copies
+}
+
+func (p *quotePlanner) copyBook(book *servicelib.Book) *servicelib.Book {
+	if book == nil {
+		return nil
+	}
+
+	if _, ok := p.originalBooks[book.ID]; !ok {
+		p.originalBooks[book.ID] = book
+	}
+	b := *book
+	if book.CurrentLend != nil {
+		lend := *book.CurrentLend
+		b.CurrentLend = &lend
+	}
+	return &b
+}
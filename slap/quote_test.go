@@ -0,0 +1,147 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/eirikbell/slap/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteLendWithLateFee(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	lateReturnDate := now.AddDate(0, 0, -2)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: lateReturnDate}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	auditLog := new(mocks.AuditLog)
+	publisher := events.NewInProcessBus()
+	publisher.Subscribe(events.TypeBookLent, func(events.Event) error {
+		t.Error("Event published for quote")
+		return nil
+	})
+
+	quote, err := QuoteLend(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithAuditLog(auditLog), WithEvents(publisher))
+	assert.Nil(t, err)
+	assert.Equal(t, &Quote{
+		BookID:           bookID,
+		CustomerID:       customerID,
+		LatestReturnDate: now.AddDate(0, 0, 7),
+		TotalPrice:       20,
+		Actions: []*PlannedAction{
+			{Type: PlannedCollectPayment, CustomerID: customerID, Amount: 20},
+			{Type: PlannedSaveBook, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, LatestReturnDate: now.AddDate(0, 0, 7)},
+			{Type: PlannedSaveBook, CustomerID: customerID, BookIDs: []string{bookID}, LatestReturnDate: now.AddDate(0, 0, 7)},
+		},
+	}, quote)
+
+	// Nothing changed on the books in the library
+	assert.Nil(t, book.CurrentLend)
+	assert.Equal(t, lateReturnDate, nonReturnedBook.CurrentLend.LatestReturnDate)
+	libraryService.AssertExpectations(t)
+	libraryService.AssertNotCalled(t, "CollectPayment", customerID, 20)
+}
+
+func TestQuoteLendMarksLogsAndSpans(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+	logger := &logging.RecordingLogger{}
+	exporter := &tracing.InMemoryExporter{}
+
+	_, err := QuoteLend(librarian, branch, bookID, customerID, libraryService, WithLogger(logger), WithTracer(tracing.NewTracer(exporter)))
+	assert.Nil(t, err)
+
+	assert.NotEmpty(t, logger.Entries)
+	for _, entry := range logger.Entries {
+		assert.Equal(t, true, entry.Field("quote"), entry.Message)
+	}
+	root := exporter.Find("QuoteLend")
+	assert.Equal(t, true, root.Attributes["quote"])
+	assert.Nil(t, exporter.Find("LendBook"))
+	libraryService.AssertExpectations(t)
+}
+
+func TestQuoteLendRenewal(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+
+	quote, err := QuoteLend(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.True(t, quote.IsRenewal)
+	assert.Equal(t, now.AddDate(0, 0, 7), quote.LatestReturnDate)
+	assert.Equal(t, 0, quote.TotalPrice)
+	assert.Len(t, quote.Actions, 1)
+	assert.Equal(t, 0, book.CurrentLend.Renewals)
+	libraryService.AssertExpectations(t)
+}
+
+func TestQuoteLendWaivedFeesOnLedger(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("GetLedgerEntries", customerID).Return([]*servicelib.LedgerEntry{}, nil)
+	auditLog := new(mocks.AuditLog)
+	override := Override{ApprovedBy: &supervisor, Reason: servicelib.OverrideReasonHardship, WaiveFees: true}
+
+	quote, err := QuoteLend(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithLedger(ledger), WithAuditLog(auditLog), WithOverride(override))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, quote.TotalPrice)
+	assert.Equal(t, []*PlannedAction{
+		{Type: PlannedPostLedgerEntry, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 20, Detail: "charge"},
+		{Type: PlannedPostLedgerEntry, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 20, Detail: "waiver"},
+		{Type: PlannedRecordOverride, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, Amount: 20, Detail: "waive-fees"},
+		{Type: PlannedSaveBook, CustomerID: customerID, BookIDs: []string{nonReturnedBook.ID}, LatestReturnDate: now.AddDate(0, 0, 7)},
+		{Type: PlannedSaveBook, CustomerID: customerID, BookIDs: []string{bookID}, LatestReturnDate: now.AddDate(0, 0, 7)},
+	}, quote.Actions)
+}
+
+func TestQuoteLendRejected(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID})
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 20}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{{ID: "1"}, {ID: "2"}, {ID: "3"}}, nil)
+
+	quote, err := QuoteLend(librarian, branch, bookID, customerID, libraryService)
+	assert.Nil(t, quote)
+	assert.Error(t, err)
+	assert.Equal(t, CodeLendLimitReached, findLendingError(err).Code)
+	libraryService.AssertExpectations(t)
+}
@@ -0,0 +1,137 @@
+package tldr
+
+import "github.com/eirikbell/slap/servicelib"
+
+// RenewResult outcome of renewing a single lend
+type RenewResult struct {
+	BookID string
+	Lend   *servicelib.Lend
+	Err    error
+}
+
+// RenewLend renews a book customer already has without the book in hand
+func RenewLend(actor servicelib.Actor, bookID string, customerID int, libraryService servicelib.LibraryService, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionRenew); err != nil {
+		return err
+	}
+
+	customer, bookLends, err := findActiveCustomer(tx, customerID, nil, true, libraryService)
+	if err != nil {
+		return err
+	}
+
+	// Decided up front when fees go on the ledger
+	if defersAutomaticLock(tx) {
+		if err := validateAccountStatus(tx, customer, bookLends); err != nil {
+			return err
+		}
+	}
+
+	book, err := findBookLend(bookID, customer, bookLends)
+	if err != nil {
+		return err
+	}
+
+	return renewEligibleBook(tx, customer, book, libraryService)
+}
+
+// RenewAll renews every eligible book customer has, reporting the outcome for each book
+func RenewAll(actor servicelib.Actor, customerID int, libraryService servicelib.LibraryService, options ...LendOption) ([]*RenewResult, error) {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionRenew); err != nil {
+		return nil, err
+	}
+
+	customer, bookLends, err := findActiveCustomer(tx, customerID, nil, true, libraryService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decided up front when fees go on the ledger
+	if defersAutomaticLock(tx) {
+		if err := validateAccountStatus(tx, customer, bookLends); err != nil {
+			return nil, err
+		}
+	}
+
+	results := []*RenewResult{}
+	for _, book := range bookLends {
+		// Returned since the lends were listed
+		if book.CurrentLend == nil {
+			continue
+		}
+		err := renewEligibleBook(tx, customer, book, libraryService)
+		results = append(results, &RenewResult{BookID: book.ID, Lend: book.CurrentLend, Err: err})
+	}
+	return results, nil
+}
+
+func findBookLend(bookID string, customer *servicelib.Customer, bookLends []*servicelib.Book) (*servicelib.Book, error) {
+	for _, book := range bookLends {
+		if book.ID == bookID && book.CurrentLend != nil {
+			return book, nil
+		}
+	}
+	return nil, lendingErrorf(CodeBookNotLendedToCustomer, 0, "Book %s is not lended to customer %d", bookID, customer.ID)
+}
+
+func renewEligibleBook(tx *transaction, customer *servicelib.Customer, book *servicelib.Book, libraryService servicelib.LibraryService) error {
+	policy := GetTierPolicy(customer)
+	if err := validateRenewable(tx, customer, book, policy); err != nil {
+		return err
+	}
+
+	return renewBook(tx, customer, book, policy, libraryService)
+}
+
+func validateRenewable(tx *transaction, customer *servicelib.Customer, book *servicelib.Book, policy TierPolicy) error {
+	// Late fees are only collected at the desk
+	if book.CurrentLend.LatestReturnDate.Before(tx.now) {
+		return lendingErrorf(CodeOverdueRenewal, 0, "Book %s is overdue and must be renewed at the desk", book.ID)
+	}
+
+	return validateRenewalLimits(customer, book, policy)
+}
+
+// Holds of other customers and the renewal limit, whether or not the book is in hand
+func validateRenewalLimits(customer *servicelib.Customer, book *servicelib.Book, policy TierPolicy) error {
+	if isHeldForOtherCustomer(book, customer.ID) {
+		return lendingErrorf(CodeBookOnHold, 0, "Book %s is on hold for another customer", book.ID)
+	}
+
+	maxRenewals := getMaxRenewals(book, policy)
+	if book.CurrentLend.Renewals >= maxRenewals {
+		return createRenewalLimitError(book, maxRenewals)
+	}
+	return nil
+}
+
+// Staff may renew beyond the tier and past holds at the desk, but not beyond what the material allows
+func validateDeskRenewal(tx *transaction, customer *servicelib.Customer, book *servicelib.Book) error {
+	if !HasPermission(tx.actor, PermissionRenewBeyondLimit) {
+		return validateRenewalLimits(customer, book, GetTierPolicy(customer))
+	}
+
+	maxRenewals := GetMaterialPolicy(book).MaxRenewals
+	if maxRenewals != tierRenewals && book.CurrentLend.Renewals >= maxRenewals {
+		return createRenewalLimitError(book, maxRenewals)
+	}
+	return nil
+}
+
+func createRenewalLimitError(book *servicelib.Book, maxRenewals int) error {
+	if maxRenewals == 0 {
+		return lendingErrorf(CodeNotRenewable, 0, "Book %s can not be renewed", book.ID)
+	}
+	return lendingErrorf(CodeRenewalLimitReached, maxRenewals, "Book %s is renewed %d times, %d is the limit", book.ID, book.CurrentLend.Renewals, maxRenewals)
+}
+
+func isHeldForOtherCustomer(book *servicelib.Book, customerID int) bool {
+	for _, hold := range book.Holds {
+		if hold.CustomerID != customerID {
+			return true
+		}
+	}
+	return false
+}
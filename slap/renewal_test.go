@@ -0,0 +1,131 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenewLendSucceeds(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: 1}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{book}, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 7), book.CurrentLend.LatestReturnDate)
+	assert.Equal(t, 2, book.CurrentLend.Renewals)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestRenewLendNotEligible(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		book        *servicelib.Book
+		expectedErr string
+	}{
+		{&servicelib.Book{ID: "654321", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}, fmt.Sprintf("Book %s is not lended to customer %d", bookID, customerID)},
+		// Returned since the lends were listed
+		{&servicelib.Book{ID: bookID}, fmt.Sprintf("Book %s is not lended to customer %d", bookID, customerID)},
+		{&servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -1)}}, fmt.Sprintf("Book %s is overdue and must be renewed at the desk", bookID)},
+		{&servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}, Holds: []*servicelib.Hold{&servicelib.Hold{CustomerID: 654321}}}, fmt.Sprintf("Book %s is on hold for another customer", bookID)},
+		{&servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1), Renewals: 2}}, fmt.Sprintf("Book %s is renewed 2 times, 2 is the limit", bookID)},
+	}
+
+	for _, tt := range testCases {
+		customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{tt.book}, nil)
+
+		err := RenewLend(kiosk, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestRenewAll(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+	expectedErr := fmt.Errorf("DB error")
+
+	renewable := &servicelib.Book{ID: "11111", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+	held := &servicelib.Book{ID: "22222", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}, Holds: []*servicelib.Hold{&servicelib.Hold{CustomerID: 654321}}}
+	failing := &servicelib.Book{ID: "33333", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{renewable, held, failing}, nil)
+	libraryService.On("SaveBook", renewable).Return(nil)
+	libraryService.On("SaveBook", failing).Return(expectedErr)
+
+	results, err := RenewAll(kiosk, customerID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, renewable.ID, results[0].BookID)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, now.AddDate(0, 0, 7), results[0].Lend.LatestReturnDate)
+	assert.Equal(t, held.ID, results[1].BookID)
+	assert.Equal(t, fmt.Sprintf("Book %s is on hold for another customer", held.ID), results[1].Err.Error())
+	assert.Equal(t, failing.ID, results[2].BookID)
+	assert.Equal(t, fmt.Sprintf("Renewal failed: %s", expectedErr.Error()), results[2].Err.Error())
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestRenewAllSkipsReturnedBooks(t *testing.T) {
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	renewable := &servicelib.Book{ID: "11111", CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, 1)}}
+	// Returned since the lends were listed
+	returned := &servicelib.Book{ID: "22222"}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(&servicelib.Customer{ID: customerID, Age: 30}, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{renewable, returned}, nil)
+	libraryService.On("SaveBook", renewable).Return(nil)
+
+	results, err := RenewAll(kiosk, customerID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, renewable.ID, results[0].BookID)
+	assert.Nil(t, results[0].Err)
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestRenewAllCustomerLocked(t *testing.T) {
+	customerID := 123456
+	customer := &servicelib.Customer{ID: customerID, IsLocked: true}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+
+	results, err := RenewAll(kiosk, customerID, libraryService)
+	assert.Nil(t, results)
+	assert.Error(t, err)
+	assert.Equal(t, "Customer account is locked", err.Error())
+
+	libraryService.AssertExpectations(t)
+}
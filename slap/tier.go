@@ -0,0 +1,40 @@
+package tldr
+
+import "github.com/eirikbell/slap/servicelib"
+
+// TierPolicy lending privileges of a membership tier
+type TierPolicy struct {
+	MaxLends    int
+	LoanDays    int
+	MaxRenewals int
+	// Percent of the day penalty charged for late returns
+	FeeRate int
+	// Percent taken off the total price for late returns
+	Discount int
+}
+
+var tierPolicies = map[servicelib.Tier]TierPolicy{
+	servicelib.TierChild:         {MaxLends: 3, LoanDays: 14, MaxRenewals: 2, FeeRate: 100, Discount: 50},
+	servicelib.TierAdult:         {MaxLends: 3, LoanDays: 7, MaxRenewals: 2, FeeRate: 100, Discount: 0},
+	servicelib.TierStudent:       {MaxLends: 5, LoanDays: 14, MaxRenewals: 3, FeeRate: 100, Discount: 25},
+	servicelib.TierSenior:        {MaxLends: 5, LoanDays: 14, MaxRenewals: 3, FeeRate: 100, Discount: 50},
+	servicelib.TierStaff:         {MaxLends: 10, LoanDays: 28, MaxRenewals: 5, FeeRate: 50, Discount: 0},
+	servicelib.TierInstitutional: {MaxLends: 20, LoanDays: 28, MaxRenewals: 5, FeeRate: 100, Discount: 0},
+}
+
+// GetTierPolicy lending privileges for the membership tier of customer
+func GetTierPolicy(customer *servicelib.Customer) TierPolicy {
+	if policy, ok := tierPolicies[customer.Tier]; ok {
+		return policy
+	}
+
+	// Customers registered before tiers were introduced
+	return tierPolicies[servicelib.TierAdult]
+}
+
+func getTier(customer *servicelib.Customer) servicelib.Tier {
+	if _, ok := tierPolicies[customer.Tier]; ok {
+		return customer.Tier
+	}
+	return servicelib.TierAdult
+}
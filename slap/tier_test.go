@@ -0,0 +1,148 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTierLendingLimit(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	testCases := []struct {
+		tier          servicelib.Tier
+		customerLends int
+		expectedErr   string
+	}{
+		{"", 3, "Customer already has 3 lended books, 3 is the limit"},
+		{servicelib.TierChild, 3, "Customer already has 3 lended books, 3 is the limit"},
+		{servicelib.TierStudent, 5, "Customer already has 5 lended books, 5 is the limit"},
+		{servicelib.TierSenior, 6, "Customer already has 6 lended books, 5 is the limit"},
+		{servicelib.TierStaff, 10, "Customer already has 10 lended books, 10 is the limit"},
+		{servicelib.TierInstitutional, 20, "Customer already has 20 lended books, 20 is the limit"},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{}
+		customer := &servicelib.Customer{ID: customerID, Age: 30, Tier: tt.tier}
+		customerLends := []*servicelib.Book{}
+		for i := 0; i < tt.customerLends; i++ {
+			customerLends = append(customerLends, &servicelib.Book{})
+		}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return(customerLends, nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService)
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestTierRenewLendingLimit(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30, Tier: servicelib.TierStudent}
+	customerLends := []*servicelib.Book{}
+	for i := 0; i < 6; i++ {
+		customerLends = append(customerLends, &servicelib.Book{})
+	}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return(customerLends, nil)
+
+	err := LendBook(librarian, branch, bookID, customerID, libraryService)
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("Cannot renew when more than 5 other books are lended, customer already has %d lended books", len(customerLends)), err.Error())
+
+	libraryService.AssertExpectations(t)
+}
+
+func TestTierLoanPeriod(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		tier             servicelib.Tier
+		expectedLoanDays int
+	}{
+		{"", 7},
+		{servicelib.TierAdult, 7},
+		{servicelib.TierChild, 14},
+		{servicelib.TierStudent, 14},
+		{servicelib.TierSenior, 14},
+		{servicelib.TierStaff, 28},
+		{servicelib.TierInstitutional, 28},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+		customer := &servicelib.Customer{ID: customerID, Age: 30, Tier: tt.tier}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{}, nil)
+		libraryService.On("SaveBook", book).Return(nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		assert.Nil(t, err)
+		assert.Equal(t, now.AddDate(0, 0, tt.expectedLoanDays), book.CurrentLend.LatestReturnDate)
+
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestTierPriceForLateReturn(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		tier            servicelib.Tier
+		age             int
+		expectedPayment int
+	}{
+		{servicelib.TierAdult, 30, 30},
+		{servicelib.TierAdult, 16, 15},
+		{servicelib.TierChild, 14, 15},
+		{servicelib.TierStudent, 22, 23},
+		{servicelib.TierStudent, 16, 15},
+		{servicelib.TierSenior, 70, 15},
+		{servicelib.TierStaff, 40, 15},
+		{servicelib.TierInstitutional, 40, 30},
+	}
+
+	for _, tt := range testCases {
+		book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+		nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -3)}}
+		customer := &servicelib.Customer{ID: customerID, Age: tt.age, Tier: tt.tier}
+
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(book)
+		libraryService.On("GetCustomer", customerID).Return(customer, nil)
+		libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+		libraryService.On("CollectPayment", customerID, tt.expectedPayment).Return(nil)
+		libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+		libraryService.On("SaveBook", book).Return(nil)
+
+		err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }))
+		assert.Nil(t, err)
+
+		libraryService.AssertExpectations(t)
+	}
+}
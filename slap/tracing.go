@@ -0,0 +1,46 @@
+package tldr
+
+import (
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/eirikbell/slap/tracing"
+)
+
+func startTrace(tx *transaction, name string, bookID string, customerID int) {
+	if tx.tracer == nil {
+		return
+	}
+
+	tx.span = tx.tracer.StartSpan(name)
+	tx.span.SetAttribute("book.id", bookID)
+	tx.span.SetAttribute("customer.id", customerID)
+	tx.span.SetAttribute("actor.id", tx.actor.ID)
+	if tx.quote {
+		tx.span.SetAttribute("quote", true)
+	}
+}
+
+func endTrace(tx *transaction, err error) {
+	if tx.span == nil {
+		return
+	}
+
+	if err != nil {
+		tx.span.SetError(err)
+		tx.span.SetAttribute("error.code", string(findLendingError(err).Code))
+	}
+	tx.span.End()
+}
+
+// Runs stage in its own span, library service calls made by the stage get spans below it
+func traceStage(tx *transaction, name string, libraryService servicelib.LibraryService, stage func(servicelib.LibraryService) error) error {
+	if tx.span == nil {
+		return stage(libraryService)
+	}
+
+	span := tx.span.StartSpan(name)
+	defer span.End()
+
+	err := stage(tracing.NewTracedLibraryService(span, libraryService))
+	span.SetError(err)
+	return err
+}
@@ -0,0 +1,78 @@
+package tldr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/eirikbell/slap/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLendBookTraced(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, DayPenalty: 10}
+	nonReturnedBook := &servicelib.Book{ID: "654321", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 20}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(nil)
+	libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{book})
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("GetLendsForCustomer", customerID).Return([]*servicelib.Book{nonReturnedBook}, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", nonReturnedBook).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	exporter := &tracing.InMemoryExporter{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithClock(func() time.Time { return now }), WithTracer(tracing.NewTracer(exporter)))
+	assert.Nil(t, err)
+
+	root := exporter.Find("LendBook")
+	assert.Equal(t, "", root.ParentID)
+	assert.Equal(t, "", root.Error)
+	assert.Equal(t, map[string]interface{}{"book.id": bookID, "customer.id": customerID, "actor.id": librarian.ID}, root.Attributes)
+
+	parents := map[string]string{}
+	for _, span := range exporter.Spans() {
+		assert.Equal(t, root.TraceID, span.TraceID)
+		parents[span.SpanID] = span.Name
+	}
+	stages := map[string][]string{}
+	for _, span := range exporter.Spans() {
+		if span.ParentID != "" {
+			stages[parents[span.ParentID]] = append(stages[parents[span.ParentID]], span.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"LendBook":           {"findBookDetails", "findActiveCustomer", "handleReturns", "lendOrRenewBook"},
+		"findBookDetails":    {"LibraryService.GetBook", "LibraryService.GetOldDbBooks"},
+		"findActiveCustomer": {"LibraryService.GetCustomer", "LibraryService.GetLendsForCustomer"},
+		"handleReturns":      {"LibraryService.CollectPayment", "LibraryService.SaveBook"},
+		"lendOrRenewBook":    {"LibraryService.SaveBook"},
+	}, stages)
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookTracedFailure(t *testing.T) {
+	customerID := 123456
+	book := &servicelib.Book{ID: "12345", CurrentLend: &servicelib.Lend{CustomerID: 654321}}
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", book.ID).Return(book)
+
+	exporter := &tracing.InMemoryExporter{}
+	err := LendBook(librarian, branch, book.ID, customerID, libraryService, WithTracer(tracing.NewTracer(exporter)))
+	assert.Error(t, err)
+
+	assert.Len(t, exporter.Spans(), 3)
+	assert.Equal(t, "Book is currently lended to customer 654321", exporter.Find("findBookDetails").Error)
+	root := exporter.Find("LendBook")
+	assert.Equal(t, "Book is currently lended to customer 654321", root.Error)
+	assert.Equal(t, "book-lended", root.Attributes["error.code"])
+	libraryService.AssertExpectations(t)
+}
@@ -0,0 +1,152 @@
+package tldr
+
+import (
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/notification"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/eirikbell/slap/tracing"
+)
+
+// Clock provides the current time, replaceable to control the transaction time
+type Clock func() time.Time
+
+// LendOption configures optional parts of a lending transaction
+type LendOption func(*transaction)
+
+type transaction struct {
+	clock Clock
+	now   time.Time
+	actor servicelib.Actor
+	// Branch the transaction happens at, empty when not at a branch
+	branch              string
+	branches            *BranchConfig
+	accountStatusPolicy AccountStatusPolicy
+	customerStore       servicelib.CustomerStore
+	ledger              servicelib.Ledger
+	debtPolicy          DebtPolicy
+	override            *Override
+	auditLog            servicelib.AuditLog
+	sender              notification.Sender
+	publisher           events.Publisher
+	tracer              tracing.Tracer
+	logger              logging.Logger
+	decisionTrace       *DecisionTrace
+	eligibilityConfig   *EligibilityConfig
+	// Replaces the rules enabled at the branch when set
+	eligibilityRules []Rule
+	// Span of the whole transaction, nil when not traced
+	span tracing.Span
+	// Planned by QuoteLend, logs and spans are marked so they are not taken for lends
+	quote bool
+}
+
+// WithClock sets the clock used to decide the transaction time
+func WithClock(clock Clock) LendOption {
+	return func(tx *transaction) {
+		tx.clock = clock
+	}
+}
+
+// WithAccountStatusPolicy sets the limits for automatically locking customer accounts
+func WithAccountStatusPolicy(policy AccountStatusPolicy) LendOption {
+	return func(tx *transaction) {
+		tx.accountStatusPolicy = policy
+	}
+}
+
+// WithCustomerStore persists changes to customers made during the transaction
+func WithCustomerStore(customerStore servicelib.CustomerStore) LendOption {
+	return func(tx *transaction) {
+		tx.customerStore = customerStore
+	}
+}
+
+// WithLedger posts late fees as debt on the ledger instead of collecting payment immediately
+func WithLedger(ledger servicelib.Ledger) LendOption {
+	return func(tx *transaction) {
+		tx.ledger = ledger
+	}
+}
+
+// WithDebtPolicy sets how much a customer may owe when fees are posted on the ledger
+func WithDebtPolicy(policy DebtPolicy) LendOption {
+	return func(tx *transaction) {
+		tx.debtPolicy = policy
+	}
+}
+
+// WithOverride makes a staff approved exception from the lending rules
+func WithOverride(override Override) LendOption {
+	return func(tx *transaction) {
+		tx.override = &override
+	}
+}
+
+// WithAuditLog records actions in the transaction for later review
+func WithAuditLog(auditLog servicelib.AuditLog) LendOption {
+	return func(tx *transaction) {
+		tx.auditLog = auditLog
+	}
+}
+
+// WithReceipts sends the customer a receipt when a book is lended, and a notice when a book on hold is ready for pickup
+func WithReceipts(sender notification.Sender) LendOption {
+	return func(tx *transaction) {
+		tx.sender = sender
+	}
+}
+
+// WithEvents publishes what happens in the transaction to other systems
+func WithEvents(publisher events.Publisher) LendOption {
+	return func(tx *transaction) {
+		tx.publisher = publisher
+	}
+}
+
+// WithTracer traces the transaction and the library service calls made in each stage
+func WithTracer(tracer tracing.Tracer) LendOption {
+	return func(tx *transaction) {
+		tx.tracer = tracer
+	}
+}
+
+// WithLogger logs the decisions made in the transaction
+func WithLogger(logger logging.Logger) LendOption {
+	return func(tx *transaction) {
+		tx.logger = logger
+	}
+}
+
+func performedBy(actor servicelib.Actor) LendOption {
+	return func(tx *transaction) {
+		tx.actor = actor
+	}
+}
+
+func newTransaction(options []LendOption, required ...LendOption) *transaction {
+	tx := &transaction{
+		clock:               time.Now,
+		accountStatusPolicy: DefaultAccountStatusPolicy,
+		debtPolicy:          DefaultDebtPolicy,
+		eligibilityConfig:   &EligibilityConfig{},
+		branches:            &BranchConfig{},
+	}
+	for _, option := range options {
+		option(tx)
+	}
+	for _, option := range required {
+		option(tx)
+	}
+
+	// The branch is only known once the required options are applied
+	if tx.eligibilityRules == nil {
+		tx.eligibilityRules = tx.eligibilityConfig.RulesFor(tx.branch)
+	}
+
+	// All rules in the transaction relate to the same point in time
+	tx.now = tx.clock()
+	return tx
+}
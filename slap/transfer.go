@@ -0,0 +1,239 @@
+package tldr
+
+import (
+	"fmt"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/notification"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// RequestTransfer sends book from where it is to branch
+func RequestTransfer(actor servicelib.Actor, bookID string, toBranch string, libraryService servicelib.LibraryService, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor))
+	if err := authorize(tx.actor, PermissionTransfer); err != nil {
+		return err
+	}
+
+	book, err := findBookOnShelf(bookID, libraryService)
+	if err != nil {
+		return err
+	}
+
+	from := GetLocation(book)
+	if from == toBranch {
+		return fmt.Errorf("Book %s is already at %s", book.ID, toBranch)
+	}
+
+	_, err = startTransfer(tx, book, from, toBranch, servicelib.TransferReasonRequest, libraryService)
+	return err
+}
+
+// ReceiveTransfer confirms book has arrived at branch, where it is shelved or kept for pickup
+func ReceiveTransfer(actor servicelib.Actor, branch string, bookID string, libraryService servicelib.LibraryService, options ...LendOption) error {
+	tx := newTransaction(options, performedBy(actor), atBranch(branch))
+	if err := authorize(tx.actor, PermissionTransfer); err != nil {
+		return err
+	}
+
+	book := libraryService.GetBook(bookID)
+	if book == nil {
+		return fmt.Errorf("Book %s not found", bookID)
+	}
+	if book.Transfer == nil {
+		return fmt.Errorf("Book %s is not in transit", book.ID)
+	}
+	// Received at the wrong branch, it has to be sent on
+	if book.Transfer.To != tx.branch {
+		return fmt.Errorf("Book %s is in transit to %s, not %s", book.ID, book.Transfer.To, tx.branch)
+	}
+
+	logDecision(tx, logging.LevelInfo, "Transfer received", logging.BookID(book.ID), logging.String("from", book.Transfer.From), logging.String("to", book.Transfer.To))
+	reason := book.Transfer.Reason
+	book.Transfer = nil
+	recordLocation(tx, book)
+	if err := libraryService.SaveBook(book); err != nil {
+		return errors.Wrap(err, "Saving received transfer failed")
+	}
+
+	// Sent here to be picked up
+	if reason == servicelib.TransferReasonHold {
+		sendHoldReady(tx, book, libraryService)
+	}
+	return nil
+}
+
+// RouteReturnedBook registers a book returned at branch and decides where it goes next, returns the transfer when it must be sent on
+func RouteReturnedBook(actor servicelib.Actor, branch string, bookID string, libraryService servicelib.LibraryService, options ...LendOption) (*servicelib.Transfer, error) {
+	tx := newTransaction(options, performedBy(actor), atBranch(branch))
+	if err := authorize(tx.actor, PermissionTransfer); err != nil {
+		return nil, err
+	}
+	if err := validateOverride(tx); err != nil {
+		return nil, err
+	}
+
+	book := libraryService.GetBook(bookID)
+	if book == nil {
+		return nil, fmt.Errorf("Book %s not found", bookID)
+	}
+	if book.Transfer != nil {
+		return nil, fmt.Errorf("Book %s is already in transit to %s", book.ID, book.Transfer.To)
+	}
+	if err := settleLateReturn(tx, book, libraryService); err != nil {
+		return nil, err
+	}
+	returned := returnBook(tx, book)
+
+	var transfer *servicelib.Transfer
+	destination, reason := getReturnDestination(book)
+	if destination == "" || destination == tx.branch {
+		recordLocation(tx, book)
+		if err := libraryService.SaveBook(book); err != nil {
+			return nil, errors.Wrap(err, "Saving returned book failed")
+		}
+		if reason == servicelib.TransferReasonHold {
+			sendHoldReady(tx, book, libraryService)
+		}
+	} else {
+		var err error
+		if transfer, err = startTransfer(tx, book, tx.branch, destination, reason, libraryService); err != nil {
+			return nil, err
+		}
+	}
+
+	if returned != nil {
+		publishEvent(tx, returned)
+	}
+	return transfer, nil
+}
+
+// Once returned the book is no longer among the lends of the customer, its late fee is settled now or never
+func settleLateReturn(tx *transaction, book *servicelib.Book, libraryService servicelib.LibraryService) error {
+	if book.CurrentLend == nil || !book.CurrentLend.LatestReturnDate.Before(tx.now) {
+		return nil
+	}
+
+	customer, err := libraryService.GetCustomer(book.CurrentLend.CustomerID)
+	if err != nil {
+		return wrapLendingError(err, CodeCustomerNotFound, "Customer not found")
+	}
+
+	books := []*servicelib.Book{book}
+	if isFeeWaived(tx) {
+		_, err := waiveLateFees(tx, customer, books)
+		return err
+	}
+
+	if tx.ledger != nil {
+		debtor, err := findDebtor(tx, customer, books, libraryService)
+		if err != nil {
+			return err
+		}
+		// The debt limit only stops new lends, a book handed in is charged whatever the customer owes
+		if priceToPay := calculateTotalPriceForLateReturn(customer, books, tx.now); priceToPay > 0 {
+			return chargeLateFees(tx, customer, debtor, books, priceToPay)
+		}
+		return nil
+	}
+
+	payer, err := findPayingCustomer(tx, customer, books, libraryService)
+	if err != nil {
+		return err
+	}
+	_, err = payLateFees(tx, customer, payer, books, libraryService)
+	return err
+}
+
+// Ends the lend of a book handed in, the event is published once the return is saved
+func returnBook(tx *transaction, book *servicelib.Book) *events.BookReturned {
+	if book.CurrentLend == nil {
+		return nil
+	}
+
+	daysLate := sumDaysLate([]*servicelib.Book{book}, tx)
+	returned := &events.BookReturned{Time: tx.now, CustomerID: book.CurrentLend.CustomerID, BookID: book.ID, DaysLate: daysLate}
+	logDecision(tx, logging.LevelInfo, "Book returned", logging.BookID(book.ID), logging.CustomerID(book.CurrentLend.CustomerID), logging.Int("daysLate", daysLate))
+	book.CurrentLend = nil
+	return returned
+}
+
+// Books lended out or already on their way can not be sent anywhere
+func findBookOnShelf(bookID string, libraryService servicelib.LibraryService) (*servicelib.Book, error) {
+	book := libraryService.GetBook(bookID)
+	if book == nil {
+		return nil, fmt.Errorf("Book %s not found", bookID)
+	}
+	if book.CurrentLend != nil {
+		return nil, fmt.Errorf("Book %s is lended to customer %d", book.ID, book.CurrentLend.CustomerID)
+	}
+	if book.Transfer != nil {
+		return nil, fmt.Errorf("Book %s is already in transit to %s", book.ID, book.Transfer.To)
+	}
+	return book, nil
+}
+
+// Next in line picks the book up, otherwise it goes back to the branch owning it
+func getReturnDestination(book *servicelib.Book) (string, servicelib.TransferReason) {
+	if len(book.Holds) > 0 {
+		if pickup := book.Holds[0].PickupBranch; pickup != "" {
+			return pickup, servicelib.TransferReasonHold
+		}
+		return book.HomeBranch, servicelib.TransferReasonHold
+	}
+	return book.HomeBranch, servicelib.TransferReasonHomeBranch
+}
+
+// Holds not picked up within a week go to the next in line
+const holdPickupDays = 7
+
+// First in line is told the book can be picked up, the book is kept for them whether or not the notice arrives
+func sendHoldReady(tx *transaction, book *servicelib.Book, libraryService servicelib.LibraryService) {
+	if tx.sender == nil || len(book.Holds) == 0 {
+		return
+	}
+
+	customerID := book.Holds[0].CustomerID
+	customer, err := libraryService.GetCustomer(customerID)
+	if err != nil {
+		logDecision(tx, logging.LevelWarn, "Hold customer not found", logging.BookID(book.ID), logging.CustomerID(customerID), logging.Error(err))
+		return
+	}
+
+	data := &notification.Data{
+		CustomerID: customer.ID,
+		BookID:     book.ID,
+		Days:       holdPickupDays,
+	}
+	_ = tx.sender.Send(customer, notification.KindHoldReady, data)
+}
+
+func startTransfer(tx *transaction, book *servicelib.Book, from string, to string, reason servicelib.TransferReason, libraryService servicelib.LibraryService) (*servicelib.Transfer, error) {
+	book.Transfer = &servicelib.Transfer{
+		From:        from,
+		To:          to,
+		Reason:      reason,
+		RequestedAt: tx.now,
+		RequestedBy: tx.actor.ID,
+	}
+	book.Location = from
+	logDecision(tx, logging.LevelInfo, "Transfer started", logging.BookID(book.ID), logging.String("from", from), logging.String("to", to), logging.String("reason", string(reason)))
+	if err := libraryService.SaveBook(book); err != nil {
+		return nil, errors.Wrap(err, "Saving transfer failed")
+	}
+	return book.Transfer, nil
+}
+
+func validateNotInTransit(tx *transaction, book *servicelib.Book) error {
+	to := ""
+	if book.Transfer != nil {
+		to = book.Transfer.To
+	}
+	traceRule(tx, RuleNotInTransit, book.Transfer == nil, map[string]interface{}{"inTransitTo": to})
+	if book.Transfer != nil {
+		return lendingErrorf(CodeBookInTransit, 0, "Book %s is in transit to %s", book.ID, to)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package tldr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/notification"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestAndReceiveTransfer(t *testing.T) {
+	bookID := "12345"
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord"}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("SaveBook", book).Return(nil)
+
+	err := RequestTransfer(librarian, bookID, "sentrum", libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	assert.Equal(t, &servicelib.Transfer{From: "nord", To: "sentrum", Reason: servicelib.TransferReasonRequest, RequestedAt: now, RequestedBy: "ola"}, book.Transfer)
+
+	err = ReceiveTransfer(librarian, "bokbuss", bookID, libraryService)
+	assert.Equal(t, "Book 12345 is in transit to sentrum, not bokbuss", err.Error())
+
+	err = ReceiveTransfer(librarian, "sentrum", bookID, libraryService)
+	assert.Nil(t, err)
+	assert.Nil(t, book.Transfer)
+	assert.Equal(t, "sentrum", GetLocation(book))
+	libraryService.AssertExpectations(t)
+}
+
+func TestRequestTransferRejected(t *testing.T) {
+	bookID := "12345"
+
+	testCases := []struct {
+		actor       servicelib.Actor
+		book        *servicelib.Book
+		expectedErr string
+	}{
+		{kiosk, &servicelib.Book{ID: bookID}, "Permission denied, kiosk kiosk-1 is not permitted to transfer"},
+		{librarian, nil, "Book 12345 not found"},
+		{librarian, &servicelib.Book{ID: bookID, CurrentLend: &servicelib.Lend{CustomerID: 123456}}, "Book 12345 is lended to customer 123456"},
+		{librarian, &servicelib.Book{ID: bookID, Transfer: &servicelib.Transfer{To: "nord"}}, "Book 12345 is already in transit to nord"},
+		{librarian, &servicelib.Book{ID: bookID, HomeBranch: "nord", Location: "sentrum"}, "Book 12345 is already at sentrum"},
+	}
+
+	for _, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(tt.book).Maybe()
+
+		err := RequestTransfer(tt.actor, bookID, "sentrum", libraryService)
+		assert.Error(t, err)
+		assert.Equal(t, tt.expectedErr, err.Error())
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestRouteReturnedBook(t *testing.T) {
+	bookID := "12345"
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		book             *servicelib.Book
+		expectedTransfer *servicelib.Transfer
+		expectedLocation string
+	}{
+		// Shelved where it was returned
+		{&servicelib.Book{ID: bookID, HomeBranch: "sentrum"}, nil, "sentrum"},
+		{&servicelib.Book{ID: bookID}, nil, "sentrum"},
+		{&servicelib.Book{ID: bookID, HomeBranch: "nord"}, &servicelib.Transfer{From: "sentrum", To: "nord", Reason: servicelib.TransferReasonHomeBranch, RequestedAt: now, RequestedBy: "ola"}, "sentrum"},
+		{&servicelib.Book{ID: bookID, HomeBranch: "nord", Holds: []*servicelib.Hold{{CustomerID: 1, PickupBranch: "bokbuss"}, {CustomerID: 2, PickupBranch: "sentrum"}}}, &servicelib.Transfer{From: "sentrum", To: "bokbuss", Reason: servicelib.TransferReasonHold, RequestedAt: now, RequestedBy: "ola"}, "sentrum"},
+		{&servicelib.Book{ID: bookID, HomeBranch: "sentrum", Holds: []*servicelib.Hold{{CustomerID: 1, PickupBranch: "sentrum"}}}, nil, "sentrum"},
+		{&servicelib.Book{ID: bookID, HomeBranch: "nord", Holds: []*servicelib.Hold{{CustomerID: 1}}}, &servicelib.Transfer{From: "sentrum", To: "nord", Reason: servicelib.TransferReasonHold, RequestedAt: now, RequestedBy: "ola"}, "sentrum"},
+	}
+
+	for i, tt := range testCases {
+		libraryService := new(mocks.LibraryService)
+		libraryService.On("GetBook", bookID).Return(tt.book)
+		libraryService.On("SaveBook", tt.book).Return(nil)
+
+		transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService, WithClock(func() time.Time { return now }))
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expectedTransfer, transfer, "case %d", i)
+		assert.Equal(t, tt.expectedTransfer, tt.book.Transfer, "case %d", i)
+		assert.Equal(t, tt.expectedLocation, GetLocation(tt.book), "case %d", i)
+		libraryService.AssertExpectations(t)
+	}
+}
+
+func TestRouteReturnedBookNotifiesHold(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord", Holds: []*servicelib.Hold{{CustomerID: customerID, PickupBranch: "sentrum"}}}
+	customer := &servicelib.Customer{ID: customerID, Contact: servicelib.ContactPreferences{Phone: "+4799999999", Channels: []servicelib.Channel{servicelib.ChannelSMS}}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("SaveBook", book).Return(nil)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	sms := &notification.RecordingNotifier{}
+	dispatcher := notification.NewDispatcher(notification.NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelSMS, sms)
+
+	transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService, WithReceipts(dispatcher))
+	assert.Nil(t, err)
+	assert.Nil(t, transfer)
+	assert.Len(t, sms.Messages, 1)
+	assert.Equal(t, notification.KindHoldReady, sms.Messages[0].Kind)
+	assert.Equal(t, "Book 12345 is ready for pickup within 7 days.", sms.Messages[0].Body)
+	libraryService.AssertExpectations(t)
+}
+
+func TestRouteReturnedBookEndsLend(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord", DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	bus := events.NewInProcessBus()
+	published := []events.Event{}
+	bus.Subscribe(events.TypeBookReturned, func(event events.Event) error {
+		published = append(published, event)
+		return nil
+	})
+
+	transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService, WithClock(func() time.Time { return now }), WithEvents(bus))
+	assert.Nil(t, err)
+	assert.Nil(t, book.CurrentLend)
+	assert.Equal(t, "nord", transfer.To)
+	assert.Equal(t, []events.Event{&events.BookReturned{Time: now, CustomerID: customerID, BookID: bookID, DaysLate: 2}}, published)
+	libraryService.AssertExpectations(t)
+}
+
+func TestRouteReturnedBookChargesLedger(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: branch, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+	expectedCharge := &servicelib.LedgerEntry{CustomerID: customerID, Type: servicelib.LedgerEntryCharge, Amount: 20, Time: now, BookIDs: []string{bookID}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("SaveBook", book).Return(nil)
+	ledger := new(mocks.Ledger)
+	ledger.On("AddLedgerEntry", expectedCharge).Return(nil)
+
+	transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService, WithClock(func() time.Time { return now }), WithLedger(ledger))
+	assert.Nil(t, err)
+	assert.Nil(t, transfer)
+	assert.Nil(t, book.CurrentLend)
+	libraryService.AssertNotCalled(t, "CollectPayment", customerID, 20)
+	libraryService.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestRouteReturnedBookPaymentFailed(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+	now := time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: branch, DayPenalty: 10, CurrentLend: &servicelib.Lend{CustomerID: customerID, LatestReturnDate: now.AddDate(0, 0, -2)}}
+	customer := &servicelib.Customer{ID: customerID, Age: 30}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	libraryService.On("CollectPayment", customerID, 20).Return(fmt.Errorf("Card declined"))
+
+	transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService, WithClock(func() time.Time { return now }))
+	assert.Nil(t, transfer)
+	assert.Equal(t, "Payment failed: Card declined", err.Error())
+	assert.NotNil(t, book.CurrentLend)
+	libraryService.AssertNotCalled(t, "SaveBook", book)
+	libraryService.AssertExpectations(t)
+}
+
+func TestReceiveTransferNotifiesHold(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord", Transfer: &servicelib.Transfer{From: "nord", To: "sentrum", Reason: servicelib.TransferReasonHold}, Holds: []*servicelib.Hold{{CustomerID: customerID, PickupBranch: "sentrum"}}}
+	customer := &servicelib.Customer{ID: customerID, Contact: servicelib.ContactPreferences{Phone: "+4799999999", Channels: []servicelib.Channel{servicelib.ChannelSMS}}}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("SaveBook", book).Return(nil)
+	libraryService.On("GetCustomer", customerID).Return(customer, nil)
+	sms := &notification.RecordingNotifier{}
+	dispatcher := notification.NewDispatcher(notification.NewDefaultCatalog())
+	dispatcher.Register(servicelib.ChannelSMS, sms)
+
+	err := ReceiveTransfer(librarian, "sentrum", bookID, libraryService, WithReceipts(dispatcher))
+	assert.Nil(t, err)
+	assert.Len(t, sms.Messages, 1)
+	assert.Equal(t, notification.KindHoldReady, sms.Messages[0].Kind)
+	assert.Equal(t, customerID, sms.Messages[0].CustomerID)
+	libraryService.AssertExpectations(t)
+}
+
+func TestRouteReturnedBookSaveFailed(t *testing.T) {
+	bookID := "12345"
+
+	book := &servicelib.Book{ID: bookID, HomeBranch: "nord"}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(book)
+	libraryService.On("SaveBook", book).Return(fmt.Errorf("DB error"))
+
+	transfer, err := RouteReturnedBook(librarian, branch, bookID, libraryService)
+	assert.Nil(t, transfer)
+	assert.Equal(t, "Saving transfer failed: DB error", err.Error())
+	libraryService.AssertExpectations(t)
+}
+
+func TestLendBookInTransit(t *testing.T) {
+	bookID := "12345"
+	customerID := 123456
+
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", bookID).Return(&servicelib.Book{ID: bookID, HomeBranch: "nord", Transfer: &servicelib.Transfer{From: "nord", To: "sentrum"}})
+
+	trace := &DecisionTrace{}
+	err := LendBook(librarian, branch, bookID, customerID, libraryService, WithDecisionTrace(trace))
+	assert.Error(t, err)
+	assert.Equal(t, "Book 12345 is in transit to sentrum", err.Error())
+	assert.Equal(t, "Book 12345 is on its way to another branch and can not be lent yet", Localize(err, "en"))
+	assert.Equal(t, &RuleEvaluation{Rule: RuleNotInTransit, Passed: false, Values: map[string]interface{}{"inTransitTo": "sentrum"}}, trace.FirstFailure())
+	libraryService.AssertExpectations(t)
+}
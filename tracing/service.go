@@ -0,0 +1,84 @@
+package tracing
+
+import "github.com/eirikbell/slap/servicelib"
+
+// TracedLibraryService starts a span around every call to the wrapped library service
+type TracedLibraryService struct {
+	starter Starter
+	next    servicelib.LibraryService
+}
+
+// NewTracedLibraryService wraps libraryService, spans are started from starter
+func NewTracedLibraryService(starter Starter, libraryService servicelib.LibraryService) *TracedLibraryService {
+	return &TracedLibraryService{starter: starter, next: libraryService}
+}
+
+func (s *TracedLibraryService) start(method string) Span {
+	return s.starter.StartSpan("LibraryService." + method)
+}
+
+// GetBook book with ID from the wrapped service
+func (s *TracedLibraryService) GetBook(bookID string) *servicelib.Book {
+	span := s.start("GetBook")
+	defer span.End()
+
+	span.SetAttribute("book.id", bookID)
+	book := s.next.GetBook(bookID)
+	span.SetAttribute("book.found", book != nil)
+	return book
+}
+
+// GetOldDbBooks books from the old database of the wrapped service
+func (s *TracedLibraryService) GetOldDbBooks() []*servicelib.Book {
+	span := s.start("GetOldDbBooks")
+	defer span.End()
+
+	books := s.next.GetOldDbBooks()
+	span.SetAttribute("books", len(books))
+	return books
+}
+
+// GetCustomer customer with ID from the wrapped service
+func (s *TracedLibraryService) GetCustomer(customerID int) (*servicelib.Customer, error) {
+	span := s.start("GetCustomer")
+	defer span.End()
+
+	span.SetAttribute("customer.id", customerID)
+	customer, err := s.next.GetCustomer(customerID)
+	span.SetError(err)
+	return customer, err
+}
+
+// GetLendsForCustomer books lent to customer from the wrapped service
+func (s *TracedLibraryService) GetLendsForCustomer(customerID int) ([]*servicelib.Book, error) {
+	span := s.start("GetLendsForCustomer")
+	defer span.End()
+
+	span.SetAttribute("customer.id", customerID)
+	books, err := s.next.GetLendsForCustomer(customerID)
+	span.SetError(err)
+	return books, err
+}
+
+// CollectPayment collects amount from customer through the wrapped service
+func (s *TracedLibraryService) CollectPayment(customerID int, amount int) error {
+	span := s.start("CollectPayment")
+	defer span.End()
+
+	span.SetAttribute("customer.id", customerID)
+	span.SetAttribute("amount", amount)
+	err := s.next.CollectPayment(customerID, amount)
+	span.SetError(err)
+	return err
+}
+
+// SaveBook saves book through the wrapped service
+func (s *TracedLibraryService) SaveBook(book *servicelib.Book) error {
+	span := s.start("SaveBook")
+	defer span.End()
+
+	span.SetAttribute("book.id", book.ID)
+	err := s.next.SaveBook(book)
+	span.SetError(err)
+	return err
+}
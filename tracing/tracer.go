@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Starter starts spans, a tracer starts new traces and a span starts its children
+type Starter interface {
+	StartSpan(name string) Span
+}
+
+// Tracer starts traces, implemented for the tracing backend in use
+type Tracer interface {
+	Starter
+}
+
+// Span timed operation within a trace, ended exactly once
+type Span interface {
+	Starter
+	SetAttribute(key string, value interface{})
+	SetError(err error)
+	End()
+}
+
+// SpanData finished span as handed to the exporter
+type SpanData struct {
+	TraceID string
+	SpanID  string
+	// Empty for the root span of a trace
+	ParentID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Message of the error the operation failed with, empty if it succeeded
+	Error string
+}
+
+// Duration time spent in the span
+func (d *SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Exporter ships finished spans to where they are looked at
+type Exporter interface {
+	Export(*SpanData)
+}
+
+// BasicTracer tracer timing spans with its clock and handing them to an exporter when ended
+type BasicTracer struct {
+	Clock func() time.Time
+
+	exporter Exporter
+	lastID   int
+	mutex    sync.Mutex
+}
+
+// NewTracer tracer exporting finished spans to exporter
+func NewTracer(exporter Exporter) *BasicTracer {
+	return &BasicTracer{Clock: time.Now, exporter: exporter}
+}
+
+// StartSpan root span of a new trace
+func (t *BasicTracer) StartSpan(name string) Span {
+	traceID := t.nextID()
+	return t.startSpan(name, traceID, "")
+}
+
+func (t *BasicTracer) startSpan(name string, traceID string, parentID string) *basicSpan {
+	return &basicSpan{
+		tracer: t,
+		data: &SpanData{
+			TraceID:    traceID,
+			SpanID:     t.nextID(),
+			ParentID:   parentID,
+			Name:       name,
+			Start:      t.Clock(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+}
+
+func (t *BasicTracer) nextID() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.lastID++
+	return fmt.Sprintf("%016x", t.lastID)
+}
+
+type basicSpan struct {
+	tracer *BasicTracer
+	data   *SpanData
+	ended  bool
+	mutex  sync.Mutex
+}
+
+func (s *basicSpan) StartSpan(name string) Span {
+	return s.tracer.startSpan(name, s.data.TraceID, s.data.SpanID)
+}
+
+func (s *basicSpan) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Attributes[key] = value
+}
+
+func (s *basicSpan) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+func (s *basicSpan) End() {
+	s.mutex.Lock()
+	// Ending twice would export the span twice
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.Clock()
+	s.mutex.Unlock()
+
+	s.tracer.exporter.Export(s.data)
+}
+
+// InMemoryExporter keeps every finished span, for tests
+type InMemoryExporter struct {
+	spans []*SpanData
+	mutex sync.Mutex
+}
+
+// Export keeps span
+func (e *InMemoryExporter) Export(span *SpanData) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans finished spans in the order they ended
+func (e *InMemoryExporter) Spans() []*SpanData {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return append([]*SpanData{}, e.spans...)
+}
+
+// Find first finished span named name, nil if there is none
+func (e *InMemoryExporter) Find(name string) *SpanData {
+	for _, span := range e.Spans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	return nil
+}
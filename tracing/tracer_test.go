@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+// Clock advancing a millisecond every time it is read
+func newTestTracer() (*BasicTracer, *InMemoryExporter) {
+	exporter := &InMemoryExporter{}
+	tracer := NewTracer(exporter)
+	tick := now
+	tracer.Clock = func() time.Time {
+		tick = tick.Add(time.Millisecond)
+		return tick
+	}
+	return tracer, exporter
+}
+
+func TestSpansExportedWhenEnded(t *testing.T) {
+	tracer, exporter := newTestTracer()
+
+	root := tracer.StartSpan("LendBook")
+	root.SetAttribute("book.id", "12345")
+	child := root.StartSpan("findBookDetails")
+	child.SetError(fmt.Errorf("Book not found"))
+	child.End()
+	root.SetError(nil)
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, &SpanData{
+		TraceID:    "0000000000000001",
+		SpanID:     "0000000000000003",
+		ParentID:   "0000000000000002",
+		Name:       "findBookDetails",
+		Start:      now.Add(2 * time.Millisecond),
+		End:        now.Add(3 * time.Millisecond),
+		Attributes: map[string]interface{}{},
+		Error:      "Book not found",
+	}, spans[0])
+	assert.Equal(t, &SpanData{
+		TraceID:    "0000000000000001",
+		SpanID:     "0000000000000002",
+		Name:       "LendBook",
+		Start:      now.Add(time.Millisecond),
+		End:        now.Add(4 * time.Millisecond),
+		Attributes: map[string]interface{}{"book.id": "12345"},
+	}, spans[1])
+	assert.Equal(t, 3*time.Millisecond, exporter.Find("LendBook").Duration())
+	assert.Nil(t, exporter.Find("SaveBook"))
+}
+
+func TestTracedLibraryService(t *testing.T) {
+	book := &servicelib.Book{ID: "12345"}
+	libraryService := new(mocks.LibraryService)
+	libraryService.On("GetBook", "12345").Return(book)
+	libraryService.On("GetOldDbBooks").Return([]*servicelib.Book{book})
+	libraryService.On("GetCustomer", 1).Return(nil, fmt.Errorf("Not found"))
+	libraryService.On("GetLendsForCustomer", 1).Return([]*servicelib.Book{}, nil)
+	libraryService.On("CollectPayment", 1, 20).Return(nil)
+	libraryService.On("SaveBook", book).Return(fmt.Errorf("DB error"))
+
+	tracer, exporter := newTestTracer()
+	root := tracer.StartSpan("test")
+	traced := NewTracedLibraryService(root, libraryService)
+
+	assert.Equal(t, book, traced.GetBook("12345"))
+	assert.Len(t, traced.GetOldDbBooks(), 1)
+	_, err := traced.GetCustomer(1)
+	assert.Error(t, err)
+	_, err = traced.GetLendsForCustomer(1)
+	assert.Nil(t, err)
+	assert.Nil(t, traced.CollectPayment(1, 20))
+	assert.Error(t, traced.SaveBook(book))
+
+	names := []string{}
+	for _, span := range exporter.Spans() {
+		assert.Equal(t, "0000000000000002", span.ParentID)
+		names = append(names, span.Name)
+	}
+	assert.Equal(t, []string{"LibraryService.GetBook", "LibraryService.GetOldDbBooks", "LibraryService.GetCustomer",
+		"LibraryService.GetLendsForCustomer", "LibraryService.CollectPayment", "LibraryService.SaveBook"}, names)
+	assert.Equal(t, map[string]interface{}{"book.id": "12345", "book.found": true}, exporter.Find("LibraryService.GetBook").Attributes)
+	assert.Equal(t, map[string]interface{}{"customer.id": 1, "amount": 20}, exporter.Find("LibraryService.CollectPayment").Attributes)
+	assert.Equal(t, "Not found", exporter.Find("LibraryService.GetCustomer").Error)
+	assert.Equal(t, "DB error", exporter.Find("LibraryService.SaveBook").Error)
+	libraryService.AssertExpectations(t)
+}
@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/pkg/errors"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Slap-Event"
+	HeaderSignature = "X-Slap-Signature"
+	HeaderDelivery  = "X-Slap-Delivery"
+)
+
+// Subscription partner endpoint receiving lending events
+type Subscription struct {
+	ID  string
+	URL string
+	// Shared with the partner, used to sign payloads
+	Secret string
+	// Events delivered to the endpoint, every event if empty
+	Types []events.Type
+}
+
+// Accepts whether events of type are delivered to the subscription
+func (s *Subscription) Accepts(eventType events.Type) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy how persistently a delivery is attempted before it is dead-lettered
+type RetryPolicy struct {
+	// Every delivery is attempted at least once, whatever the limit
+	MaxAttempts int
+	// Wait before the second attempt, doubled for every attempt after
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy five attempts over about half a minute
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+// Attempts made before giving up
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Backoff wait before attempt number attempt, counting from 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 2; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// Deliveries waiting for a worker before new ones are dead-lettered right away
+const queueSize = 1000
+
+// Webhooks delivers events to subscribed partner endpoints, off the path of the transaction publishing them
+type Webhooks struct {
+	Client      *http.Client
+	RetryPolicy RetryPolicy
+	Clock       func() time.Time
+	// Waits between attempts, replaceable to avoid waiting in tests
+	Sleep func(time.Duration)
+	// Deliveries made at the same time, read by Start
+	Workers int
+	// Logs deliveries lost because dead-lettering failed, nil to not log
+	Logger logging.Logger
+
+	deadLetters   servicelib.DeadLetterStore
+	subscriptions []*Subscription
+	queue         chan *delivery
+	stopped       bool
+	workers       sync.WaitGroup
+	mutex         sync.RWMutex
+}
+
+type delivery struct {
+	id           string
+	subscription *Subscription
+	eventType    events.Type
+	payload      []byte
+}
+
+// NewWebhooks webhooks without subscriptions, deliveries given up on are stored in deadLetters
+func NewWebhooks(deadLetters servicelib.DeadLetterStore) *Webhooks {
+	return &Webhooks{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		RetryPolicy: DefaultRetryPolicy,
+		Clock:       time.Now,
+		Sleep:       time.Sleep,
+		Workers:     4,
+		deadLetters: deadLetters,
+		queue:       make(chan *delivery, queueSize),
+	}
+}
+
+// Start runs the workers delivering queued events
+func (w *Webhooks) Start() {
+	for i := 0; i < w.Workers; i++ {
+		w.workers.Add(1)
+		go w.work()
+	}
+}
+
+// Stop waits for queued deliveries to finish, events handled after are dead-lettered
+func (w *Webhooks) Stop() {
+	w.mutex.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.queue)
+	}
+	w.mutex.Unlock()
+
+	w.workers.Wait()
+}
+
+func (w *Webhooks) work() {
+	defer w.workers.Done()
+
+	for d := range w.queue {
+		// Nobody is waiting for the delivery, all that is left is to log it
+		if err := w.deliverOrDeadLetter(d); err != nil {
+			w.logDeadLetterFailure(d, err)
+		}
+	}
+}
+
+func (w *Webhooks) logDeadLetterFailure(d *delivery, err error) {
+	if w.Logger == nil {
+		return
+	}
+	w.Logger.Log(logging.LevelError, "Dead-lettering delivery failed", logging.String("deliveryId", d.id), logging.String("subscriptionId", d.subscription.ID),
+		logging.String("eventType", string(d.eventType)), logging.Error(err))
+}
+
+// Add subscription receiving events from now on
+func (w *Webhooks) Add(subscription *Subscription) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.subscriptions = append(w.subscriptions, subscription)
+}
+
+// SubscribeTo delivers every event published on bus
+func (w *Webhooks) SubscribeTo(bus events.EventBus) {
+	for _, eventType := range events.AllTypes {
+		bus.Subscribe(eventType, w.Handle)
+	}
+}
+
+// Handle queues delivery of event to every subscription accepting it, deliveries that can not be queued are dead-lettered
+func (w *Webhooks) Handle(event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "Encoding %s failed", event.EventType())
+	}
+
+	fail := []string{}
+	for _, subscription := range w.getSubscriptions(event.EventType()) {
+		d := &delivery{id: newDeliveryID(), subscription: subscription, eventType: event.EventType(), payload: payload}
+		if w.enqueue(d) {
+			continue
+		}
+
+		// Other subscriptions still get the event
+		if err := w.deadLetter(d, 0, fmt.Errorf("Delivery could not be queued")); err != nil {
+			fail = append(fail, fmt.Sprintf("%s (%s)", subscription.ID, err.Error()))
+		}
+	}
+	if len(fail) > 0 {
+		return fmt.Errorf("Dead-lettering %s failed for %s", event.EventType(), strings.Join(fail, ", "))
+	}
+	return nil
+}
+
+// Never blocks the publisher, a full queue means the endpoints are far behind
+func (w *Webhooks) enqueue(d *delivery) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.stopped {
+		return false
+	}
+	select {
+	case w.queue <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	// Falls back to a time based ID, unique enough for partners ignoring duplicates
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func (w *Webhooks) getSubscriptions(eventType events.Type) []*Subscription {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	subscriptions := []*Subscription{}
+	for _, subscription := range w.subscriptions {
+		if subscription.Accepts(eventType) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions
+}
+
+// Only a failing dead-letter store is an error, the delivery is otherwise kept for redelivery
+func (w *Webhooks) deliverOrDeadLetter(d *delivery) error {
+	attempts, err := w.deliverWithRetry(d)
+	if err == nil {
+		return nil
+	}
+
+	return w.deadLetter(d, attempts, err)
+}
+
+func (w *Webhooks) deadLetter(d *delivery, attempts int, err error) error {
+	return w.deadLetters.AddDeadLetter(&servicelib.DeadLetter{
+		DeliveryID:     d.id,
+		SubscriptionID: d.subscription.ID,
+		URL:            d.subscription.URL,
+		EventType:      string(d.eventType),
+		Payload:        d.payload,
+		Attempts:       attempts,
+		LastError:      err.Error(),
+		Time:           w.Clock(),
+	})
+}
+
+func (w *Webhooks) deliverWithRetry(d *delivery) (int, error) {
+	var err error
+	maxAttempts := w.RetryPolicy.attempts()
+	attempt := 1
+	for ; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			w.Sleep(w.RetryPolicy.Backoff(attempt))
+		}
+
+		var retry bool
+		if retry, err = w.deliver(d); err == nil || !retry {
+			break
+		}
+	}
+	if attempt > maxAttempts {
+		attempt = maxAttempts
+	}
+	return attempt, err
+}
+
+// Tells whether a failed delivery is worth another attempt, every attempt has the same delivery ID
+func (w *Webhooks) deliver(d *delivery) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, d.subscription.URL, bytes.NewReader(d.payload))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, string(d.eventType))
+	request.Header.Set(HeaderDelivery, d.id)
+	request.Header.Set(HeaderSignature, Sign(d.subscription.Secret, d.payload))
+
+	response, err := w.Client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	return isRetryable(response.StatusCode), fmt.Errorf("Endpoint responded %s", response.Status)
+}
+
+// The endpoint rejected the payload itself, sending it again does not help
+func isRetryable(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout
+}
+
+// Sign HMAC-SHA256 of payload with secret, as sent in the signature header
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify whether signature is the signature of payload with secret, for partners checking deliveries
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eirikbell/slap/events"
+	"github.com/eirikbell/slap/logging"
+	"github.com/eirikbell/slap/mocks"
+	"github.com/eirikbell/slap/servicelib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Date(2019, time.September, 9, 12, 0, 0, 0, time.UTC)
+
+type request struct {
+	Header http.Header
+	Body   string
+}
+
+// Partner endpoint responding with statuses in order, the last one repeated
+func newEndpoint(statuses ...int) (*httptest.Server, *[]request) {
+	requests := []request{}
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{Header: r.Header, Body: string(body)})
+		status := statuses[len(statuses)-1]
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		w.WriteHeader(status)
+	}))
+	return server, &requests
+}
+
+// A single worker keeps the waits in order
+func newTestWebhooks(deadLetters servicelib.DeadLetterStore, waits *[]time.Duration) *Webhooks {
+	webhooks := NewWebhooks(deadLetters)
+	webhooks.Clock = func() time.Time { return now }
+	webhooks.Sleep = func(d time.Duration) { *waits = append(*waits, d) }
+	webhooks.Workers = 1
+	webhooks.Start()
+	return webhooks
+}
+
+func TestHandleDeliversSignedPayload(t *testing.T) {
+	server, requests := newEndpoint(http.StatusOK)
+	defer server.Close()
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(new(mocks.DeadLetterStore), &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL, Secret: "s3cret"})
+
+	err := webhooks.Handle(&events.BookLent{Time: now, CustomerID: 1, BookID: "12345", LatestReturnDate: now.AddDate(0, 0, 7), PerformedBy: "ola"})
+	assert.Nil(t, err)
+	webhooks.Stop()
+
+	payload := `{"Time":"2019-09-09T12:00:00Z","CustomerID":1,"BookID":"12345","LatestReturnDate":"2019-09-16T12:00:00Z","PerformedBy":"ola"}`
+	assert.Len(t, *requests, 1)
+	assert.Equal(t, payload, (*requests)[0].Body)
+	assert.Equal(t, "book-lent", (*requests)[0].Header.Get(HeaderEvent))
+	assert.Len(t, (*requests)[0].Header.Get(HeaderDelivery), 32)
+	assert.Equal(t, "application/json", (*requests)[0].Header.Get("Content-Type"))
+	assert.True(t, Verify("s3cret", []byte(payload), (*requests)[0].Header.Get(HeaderSignature)))
+	assert.False(t, Verify("other", []byte(payload), (*requests)[0].Header.Get(HeaderSignature)))
+	assert.Empty(t, waits)
+}
+
+func TestHandleFiltersOnEventType(t *testing.T) {
+	payments, paymentRequests := newEndpoint(http.StatusOK)
+	defer payments.Close()
+	everything, everythingRequests := newEndpoint(http.StatusNoContent)
+	defer everything.Close()
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(new(mocks.DeadLetterStore), &waits)
+	webhooks.Add(&Subscription{ID: "accounting", URL: payments.URL, Types: []events.Type{events.TypeFeeCollected}})
+	webhooks.Add(&Subscription{ID: "warehouse", URL: everything.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.BookLent{}))
+	assert.Nil(t, webhooks.Handle(&events.BookReturned{}))
+	assert.Nil(t, webhooks.Handle(&events.FeeCollected{}))
+	webhooks.Stop()
+
+	assert.Len(t, *paymentRequests, 1)
+	assert.Equal(t, "fee-collected", (*paymentRequests)[0].Header.Get(HeaderEvent))
+	assert.Len(t, *everythingRequests, 3)
+	assert.NotEqual(t, (*everythingRequests)[0].Header.Get(HeaderDelivery), (*everythingRequests)[1].Header.Get(HeaderDelivery))
+	assert.NotEqual(t, (*everythingRequests)[2].Header.Get(HeaderDelivery), (*paymentRequests)[0].Header.Get(HeaderDelivery))
+}
+
+func TestHandleRetriesWithBackoff(t *testing.T) {
+	server, requests := newEndpoint(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK)
+	defer server.Close()
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(new(mocks.DeadLetterStore), &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.FeeCollected{}))
+	webhooks.Stop()
+	assert.Len(t, *requests, 4)
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}, waits)
+	// Retries are the same delivery
+	for _, r := range *requests {
+		assert.Equal(t, (*requests)[0].Header.Get(HeaderDelivery), r.Header.Get(HeaderDelivery))
+	}
+}
+
+func TestHandleDeadLettersAfterAllAttempts(t *testing.T) {
+	server, requests := newEndpoint(http.StatusInternalServerError)
+	defer server.Close()
+
+	deadLetters := new(mocks.DeadLetterStore)
+	var deadLetter *servicelib.DeadLetter
+	deadLetters.On("AddDeadLetter", mock.Anything).Run(func(args mock.Arguments) {
+		deadLetter = args.Get(0).(*servicelib.DeadLetter)
+	}).Return(nil)
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(deadLetters, &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.BookReturned{CustomerID: 1, BookID: "12345", DaysLate: 2}))
+	webhooks.Stop()
+	assert.Equal(t, &servicelib.DeadLetter{
+		DeliveryID:     (*requests)[0].Header.Get(HeaderDelivery),
+		SubscriptionID: "partner",
+		URL:            server.URL,
+		EventType:      "book-returned",
+		Payload:        []byte(`{"Time":"0001-01-01T00:00:00Z","CustomerID":1,"BookID":"12345","DaysLate":2}`),
+		Attempts:       5,
+		LastError:      "Endpoint responded 500 Internal Server Error",
+		Time:           now,
+	}, deadLetter)
+	assert.Len(t, *requests, 5)
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}, waits)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestHandleDoesNotRetryRejectedPayload(t *testing.T) {
+	server, requests := newEndpoint(http.StatusBadRequest)
+	defer server.Close()
+
+	deadLetters := new(mocks.DeadLetterStore)
+	deadLetters.On("AddDeadLetter", mock.MatchedBy(func(deadLetter *servicelib.DeadLetter) bool {
+		return deadLetter.Attempts == 1 && deadLetter.LastError == "Endpoint responded 400 Bad Request"
+	})).Return(nil)
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(deadLetters, &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.BookLent{}))
+	webhooks.Stop()
+	assert.Len(t, *requests, 1)
+	assert.Empty(t, waits)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestHandleAttemptsOnceWithoutRetryPolicy(t *testing.T) {
+	server, requests := newEndpoint(http.StatusInternalServerError)
+	defer server.Close()
+
+	deadLetters := new(mocks.DeadLetterStore)
+	deadLetters.On("AddDeadLetter", mock.MatchedBy(func(deadLetter *servicelib.DeadLetter) bool {
+		return deadLetter.Attempts == 1 && deadLetter.LastError == "Endpoint responded 500 Internal Server Error"
+	})).Return(nil)
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(deadLetters, &waits)
+	webhooks.RetryPolicy = RetryPolicy{}
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.BookLent{}))
+	webhooks.Stop()
+	assert.Len(t, *requests, 1)
+	assert.Empty(t, waits)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestHandleRetriesUnreachableEndpoint(t *testing.T) {
+	server, _ := newEndpoint(http.StatusOK)
+	server.Close()
+
+	deadLetters := new(mocks.DeadLetterStore)
+	deadLetters.On("AddDeadLetter", mock.MatchedBy(func(deadLetter *servicelib.DeadLetter) bool {
+		return deadLetter.Attempts == 3
+	})).Return(fmt.Errorf("DB error"))
+
+	waits := []time.Duration{}
+	logger := &logging.RecordingLogger{}
+	webhooks := newTestWebhooks(deadLetters, &waits)
+	webhooks.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Second}
+	webhooks.Logger = logger
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	assert.Nil(t, webhooks.Handle(&events.LendRenewed{}))
+	webhooks.Stop()
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, waits)
+	entry := logger.Find("Dead-lettering delivery failed")
+	assert.NotNil(t, entry)
+	assert.Equal(t, "partner", entry.Field("subscriptionId"))
+	assert.Equal(t, "DB error", entry.Field("error"))
+	deadLetters.AssertExpectations(t)
+}
+
+func TestSubscribeToBus(t *testing.T) {
+	server, requests := newEndpoint(http.StatusOK)
+	defer server.Close()
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(new(mocks.DeadLetterStore), &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL, Types: []events.Type{events.TypeBookReturned}})
+	bus := events.NewInProcessBus()
+	webhooks.SubscribeTo(bus)
+
+	assert.Nil(t, bus.Publish(&events.BookLent{}))
+	assert.Nil(t, bus.Publish(&events.BookReturned{}))
+	webhooks.Stop()
+	assert.Len(t, *requests, 1)
+}
+
+func TestHandleDoesNotWaitForEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(new(mocks.DeadLetterStore), &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: server.URL})
+
+	done := make(chan error)
+	go func() { done <- webhooks.Handle(&events.BookLent{}) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Error("Handle waited for the endpoint")
+	}
+
+	close(release)
+	webhooks.Stop()
+}
+
+func TestHandleAfterStopDeadLetters(t *testing.T) {
+	deadLetters := new(mocks.DeadLetterStore)
+	deadLetters.On("AddDeadLetter", mock.MatchedBy(func(deadLetter *servicelib.DeadLetter) bool {
+		return deadLetter.Attempts == 0 && deadLetter.LastError == "Delivery could not be queued" && len(deadLetter.DeliveryID) == 32
+	})).Return(nil)
+
+	waits := []time.Duration{}
+	webhooks := newTestWebhooks(deadLetters, &waits)
+	webhooks.Add(&Subscription{ID: "partner", URL: "http://partner.example"})
+	webhooks.Stop()
+
+	assert.Nil(t, webhooks.Handle(&events.BookLent{}))
+	deadLetters.AssertExpectations(t)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, policy.Backoff(2))
+	assert.Equal(t, 2*time.Second, policy.Backoff(3))
+	assert.Equal(t, 4*time.Second, policy.Backoff(4))
+	assert.Equal(t, 5*time.Second, policy.Backoff(5))
+	assert.Equal(t, 5*time.Second, policy.Backoff(9))
+}